@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"github.com/jgbaldwinbrown/permuvals/pkg"
+)
+
+func main() {
+	os.Exit(permuvals.Main(os.Args[1:], os.Stdout, os.Stderr))
+}
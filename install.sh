@@ -3,4 +3,5 @@
 BINDIR="${1}"
 
 go build cmd/permute_intervals.go
-cp permute_intervals "${BINDIR}/"
+go build -o permuvals ./cmd/permuvals
+cp permute_intervals permuvals "${BINDIR}/"
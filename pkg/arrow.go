@@ -0,0 +1,301 @@
+package permuvals
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// A minimal writer for the Apache Arrow IPC stream format
+// (https://arrow.apache.org/docs/format/Columnar.html#ipc-streaming-format),
+// supporting only non-nullable int64 and utf8 columns. The metadata is
+// flatbuffer-encoded; since only a handful of tables are needed, they are
+// laid out by hand here rather than pulling in the flatbuffers and arrow
+// modules. TestArrowGolden checks the output against a stream written by the
+// Arrow Go library, and testdata/arrowgolden can read any stream with it.
+
+// A flatbuffer object that can be referred to by offset
+type fbObj interface {
+	// Append the object to b, returning the position offsets should point at
+	write(b *fbBuilder) int
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v >> 8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v >> 8), byte(v >> 16), byte(v >> 24))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v)), uint32(v >> 32))
+}
+
+type fbBuilder struct {
+	buf []byte
+}
+
+func (b *fbBuilder) pad(align int) {
+	for len(b.buf) % align != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) putUint32(pos int, v uint32) {
+	binary.LittleEndian.PutUint32(b.buf[pos:], v)
+}
+
+// Reserve a uoffset at the current position and write obj after it later
+type fbPatch struct {
+	pos int
+	obj fbObj
+}
+
+func (b *fbBuilder) writePatches(patches []fbPatch) {
+	for _, p := range patches {
+		target := p.obj.write(b)
+		b.putUint32(p.pos, uint32(target - p.pos))
+	}
+}
+
+// Build a finished flatbuffer with root as its root table
+func fbFinish(root fbObj) []byte {
+	b := &fbBuilder{}
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	b.writePatches([]fbPatch{{0, root}})
+	b.pad(8)
+	return b.buf
+}
+
+// One table field: either inline scalar bytes, or an offset to ref
+type fbField struct {
+	slot int
+	scalar []byte
+	ref fbObj
+}
+
+type fbTable []fbField
+
+func fbInt8(slot int, v uint8) fbField { return fbField{slot: slot, scalar: []byte{v}} }
+func fbInt16(slot int, v int16) fbField {
+	return fbField{slot: slot, scalar: appendUint16(nil, uint16(v))}
+}
+func fbInt32(slot int, v int32) fbField {
+	return fbField{slot: slot, scalar: appendUint32(nil, uint32(v))}
+}
+func fbInt64(slot int, v int64) fbField {
+	return fbField{slot: slot, scalar: appendUint64(nil, uint64(v))}
+}
+func fbRef(slot int, obj fbObj) fbField { return fbField{slot: slot, ref: obj} }
+
+func (t fbTable) write(b *fbBuilder) int {
+	nslots := 0
+	for _, f := range t {
+		if f.slot + 1 > nslots {
+			nslots = f.slot + 1
+		}
+	}
+
+	// Lay out the fields: the soffset to the vtable, then each field aligned
+	// to its own size, assuming the table starts 8-aligned
+	offsets := make([]int, len(t))
+	size := 4
+	for i, f := range t {
+		width := len(f.scalar)
+		if f.ref != nil {
+			width = 4
+		}
+		for size % width != 0 {
+			size++
+		}
+		offsets[i] = size
+		size += width
+	}
+
+	b.pad(2)
+	vtable := len(b.buf)
+	vt := make([]byte, 4 + 2 * nslots)
+	binary.LittleEndian.PutUint16(vt[0:], uint16(len(vt)))
+	binary.LittleEndian.PutUint16(vt[2:], uint16(size))
+	for i, f := range t {
+		binary.LittleEndian.PutUint16(vt[4 + 2 * f.slot:], uint16(offsets[i]))
+	}
+	b.buf = append(b.buf, vt...)
+
+	b.pad(8)
+	start := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	b.putUint32(start, uint32(start - vtable))
+	var patches []fbPatch
+	for i, f := range t {
+		if f.ref != nil {
+			patches = append(patches, fbPatch{start + offsets[i], f.ref})
+		} else {
+			copy(b.buf[start + offsets[i]:], f.scalar)
+		}
+	}
+	b.writePatches(patches)
+	return start
+}
+
+type fbString string
+
+func (s fbString) write(b *fbBuilder) int {
+	b.pad(4)
+	start := len(b.buf)
+	b.buf = appendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return start
+}
+
+// A vector of structs, already encoded, each elemSize bytes and 8-aligned
+type fbStructVector struct {
+	data []byte
+	elemSize int
+}
+
+func (v fbStructVector) write(b *fbBuilder) int {
+	// the elements follow the 4-byte length and must be 8-aligned
+	b.pad(8)
+	b.buf = append(b.buf, 0, 0, 0, 0)
+	start := len(b.buf)
+	b.buf = appendUint32(b.buf, uint32(len(v.data) / v.elemSize))
+	b.buf = append(b.buf, v.data...)
+	return start
+}
+
+type fbVector []fbObj
+
+func (v fbVector) write(b *fbBuilder) int {
+	b.pad(4)
+	start := len(b.buf)
+	b.buf = appendUint32(b.buf, uint32(len(v)))
+	patches := make([]fbPatch, 0, len(v))
+	for _, obj := range v {
+		patches = append(patches, fbPatch{len(b.buf), obj})
+		b.buf = append(b.buf, 0, 0, 0, 0)
+	}
+	b.writePatches(patches)
+	return start
+}
+
+// Enum values from the Arrow Schema.fbs and Message.fbs definitions
+const (
+	arrowMetadataV5 = 4
+	arrowHeaderSchema = 1
+	arrowHeaderRecordBatch = 3
+	arrowTypeInt = 2
+	arrowTypeUtf8 = 5
+)
+
+// One column of an Arrow record batch
+type ArrowColumn struct {
+	Name string
+	// Exactly one of these is set
+	Int64s []int64
+	Strings []string
+}
+
+func (c ArrowColumn) len() int {
+	if c.Strings != nil {
+		return len(c.Strings)
+	}
+	return len(c.Int64s)
+}
+
+func (c ArrowColumn) field() fbTable {
+	typeType, typ := uint8(arrowTypeInt), fbTable{fbInt32(0, 64), fbInt8(1, 1)}
+	if c.Strings != nil {
+		typeType, typ = arrowTypeUtf8, fbTable{}
+	}
+	return fbTable {
+		fbRef(0, fbString(c.Name)),
+		fbInt8(1, 0),
+		fbInt8(2, typeType),
+		fbRef(3, typ),
+		fbRef(5, fbVector{}),
+	}
+}
+
+// Append buf to body, padded to 8 bytes, and record it as a Buffer struct
+func appendArrowBuffer(body, buffers []byte, buf []byte) ([]byte, []byte) {
+	buffers = appendUint64(buffers, uint64(len(body)))
+	buffers = appendUint64(buffers, uint64(len(buf)))
+	body = append(body, buf...)
+	for len(body) % 8 != 0 {
+		body = append(body, 0)
+	}
+	return body, buffers
+}
+
+func writeArrowMessage(w io.Writer, header fbTable, headerType uint8, body []byte) error {
+	msg := fbFinish(fbTable {
+		fbInt16(0, arrowMetadataV5),
+		fbInt8(1, headerType),
+		fbRef(2, header),
+		fbInt64(3, int64(len(body))),
+	})
+	prefix := appendUint32(nil, 0xFFFFFFFF)
+	prefix = appendUint32(prefix, uint32(len(msg)))
+	for _, part := range [][]byte{prefix, msg, body} {
+		if _, err := w.Write(part); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write cols, which must all be the same length, as an Arrow IPC stream
+// holding a schema and a single record batch
+func WriteArrowStream(w io.Writer, cols []ArrowColumn) error {
+	fields := make(fbVector, 0, len(cols))
+	for _, c := range cols {
+		fields = append(fields, c.field())
+	}
+	schema := fbTable{fbInt16(0, 0), fbRef(1, fields)}
+	if err := writeArrowMessage(w, schema, arrowHeaderSchema, nil); err != nil {
+		return err
+	}
+
+	nrows := 0
+	if len(cols) > 0 {
+		nrows = cols[0].len()
+	}
+	var body, nodes, buffers []byte
+	for _, c := range cols {
+		nodes = appendUint64(nodes, uint64(c.len()))
+		nodes = appendUint64(nodes, 0)
+		// no nulls, so the validity bitmap can be empty
+		body, buffers = appendArrowBuffer(body, buffers, nil)
+		if c.Strings != nil {
+			offsets := make([]byte, 0, 4 * (len(c.Strings) + 1))
+			var data []byte
+			offsets = appendUint32(offsets, 0)
+			for _, s := range c.Strings {
+				data = append(data, s...)
+				offsets = appendUint32(offsets, uint32(len(data)))
+			}
+			body, buffers = appendArrowBuffer(body, buffers, offsets)
+			body, buffers = appendArrowBuffer(body, buffers, data)
+		} else {
+			data := make([]byte, 0, 8 * len(c.Int64s))
+			for _, x := range c.Int64s {
+				data = appendUint64(data, uint64(x))
+			}
+			body, buffers = appendArrowBuffer(body, buffers, data)
+		}
+	}
+	batch := fbTable {
+		fbInt64(0, int64(nrows)),
+		fbRef(1, fbStructVector{nodes, 16}),
+		fbRef(2, fbStructVector{buffers, 16}),
+	}
+	if err := writeArrowMessage(w, batch, arrowHeaderRecordBatch, body); err != nil {
+		return err
+	}
+
+	// end-of-stream marker
+	_, err := w.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,249 @@
+package permuvals
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"testing"
+)
+
+// Just enough of a flatbuffer reader to check WriteArrowStream against the spec
+type fbReader struct {
+	t *testing.T
+	buf []byte
+}
+
+func (r fbReader) u16(p int) int { return int(binary.LittleEndian.Uint16(r.buf[p:])) }
+func (r fbReader) u32(p int) int { return int(binary.LittleEndian.Uint32(r.buf[p:])) }
+func (r fbReader) i64(p int) int64 { return int64(binary.LittleEndian.Uint64(r.buf[p:])) }
+
+func (r fbReader) deref(p int) int {
+	target := p + r.u32(p)
+	if target % 4 != 0 {
+		r.t.Errorf("offset at %v points to unaligned %v", p, target)
+	}
+	return target
+}
+
+// Position of a table field, or -1 if absent
+func (r fbReader) field(table, slot int) int {
+	vt := table - int(int32(r.u32(table)))
+	if 4 + 2 * slot >= r.u16(vt) {
+		return -1
+	}
+	off := r.u16(vt + 4 + 2 * slot)
+	if off == 0 {
+		return -1
+	}
+	return table + off
+}
+
+func (r fbReader) str(p int) string {
+	p = r.deref(p)
+	return string(r.buf[p + 4 : p + 4 + r.u32(p)])
+}
+
+// Read one message from a stream, returning its root table and body
+func readArrowMessage(t *testing.T, stream []byte) (fbReader, int, []byte, []byte) {
+	if binary.LittleEndian.Uint32(stream) != 0xFFFFFFFF {
+		t.Fatalf("missing continuation marker")
+	}
+	n := int(binary.LittleEndian.Uint32(stream[4:]))
+	if n % 8 != 0 {
+		t.Errorf("metadata length %v is not a multiple of 8", n)
+	}
+	r := fbReader{t, stream[8 : 8 + n]}
+	root := r.deref(0)
+	if v := r.u16(r.field(root, 0)); v != arrowMetadataV5 {
+		t.Errorf("metadata version %v", v)
+	}
+	// fields equal to their default, such as an empty body, may be left out
+	bodyLen := 0
+	if p := r.field(root, 3); p >= 0 {
+		bodyLen = int(r.i64(p))
+	}
+	body := stream[8 + n : 8 + n + bodyLen]
+	return r, root, body, stream[8 + n + bodyLen:]
+}
+
+// Decode a stream of a schema and one record batch of non-nullable int64 and
+// utf8 columns, as written by WriteArrowStream
+func readArrowColumns(t *testing.T, stream []byte) (cols []ArrowColumn) {
+	r, root, _, stream := readArrowMessage(t, stream)
+	if r.buf[r.field(root, 1)] != arrowHeaderSchema {
+		t.Fatalf("first message is not a schema")
+	}
+	fields := r.deref(r.field(r.deref(r.field(root, 2)), 1))
+	for i := 0; i < r.u32(fields); i++ {
+		f := r.deref(fields + 4 + 4 * i)
+		col := ArrowColumn{Name: r.str(r.field(f, 0))}
+		switch typ := r.buf[r.field(f, 2)]; typ {
+		case arrowTypeUtf8:
+			col.Strings = []string{}
+		case arrowTypeInt:
+			intType := r.deref(r.field(f, 3))
+			if r.u32(r.field(intType, 0)) != 64 || r.buf[r.field(intType, 1)] != 1 {
+				t.Fatalf("field %v is not a signed 64-bit int", col.Name)
+			}
+			col.Int64s = []int64{}
+		default:
+			t.Fatalf("field %v has type %v", col.Name, typ)
+		}
+		cols = append(cols, col)
+	}
+
+	r, root, body, stream := readArrowMessage(t, stream)
+	if r.buf[r.field(root, 1)] != arrowHeaderRecordBatch {
+		t.Fatalf("second message is not a record batch")
+	}
+	batch := r.deref(r.field(root, 2))
+	nrows := int(r.i64(r.field(batch, 0)))
+	nodes, buffers := r.deref(r.field(batch, 1)), r.deref(r.field(batch, 2))
+	next := 0
+	buffer := func() []byte {
+		p := buffers + 4 + 16 * next
+		next++
+		off, n := int(r.i64(p)), int(r.i64(p + 8))
+		return body[off : off + n]
+	}
+	for i := range cols {
+		if n, nulls := int(r.i64(nodes + 4 + 16 * i)), r.i64(nodes + 12 + 16 * i); n != nrows || nulls != 0 {
+			t.Fatalf("column %v has %v rows and %v nulls", cols[i].Name, n, nulls)
+		}
+		buffer() // validity
+		if cols[i].Strings != nil {
+			offsets, data := buffer(), buffer()
+			for row := 0; row < nrows; row++ {
+				start, end := binary.LittleEndian.Uint32(offsets[4 * row:]), binary.LittleEndian.Uint32(offsets[4 * row + 4:])
+				cols[i].Strings = append(cols[i].Strings, string(data[start:end]))
+			}
+			continue
+		}
+		data := buffer()
+		for row := 0; row < nrows; row++ {
+			cols[i].Int64s = append(cols[i].Int64s, int64(binary.LittleEndian.Uint64(data[8 * row:])))
+		}
+	}
+
+	if !bytes.Equal(stream, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}) {
+		t.Errorf("stream does not end with the end-of-stream marker: %v", stream)
+	}
+	return
+}
+
+// The table in testdata/null.arrow, which was written by the Apache Arrow Go
+// implementation with testdata/arrowgolden. The same program can print any
+// stream with a real Arrow reader, e.g. the null_counts.arrow written by -arrow:
+//
+//	cd testdata/arrowgolden && go run . read < null.arrow
+func goldenArrowColumns() []ArrowColumn {
+	return []ArrowColumn {
+		ArrowColumn{Name: "iteration", Int64s: []int64{0, 1, 0, 1, 2}},
+		ArrowColumn{Name: "name", Strings: []string{"first", "first", "first:second", "first:second", ""}},
+		ArrowColumn{Name: "n_components", Int64s: []int64{1, 1, 2, 2, 0}},
+		ArrowColumn{Name: "count", Int64s: []int64{3, 0, 1, -1, 1 << 40}},
+		ArrowColumn{Name: "covered", Int64s: []int64{30, 0, 8, 0, 7}},
+	}
+}
+
+// The flatbuffer layout of WriteArrowStream differs from that of the Arrow
+// libraries, so the streams are compared by decoding them, which also checks
+// readArrowColumns against a real writer
+func TestArrowGolden(t *testing.T) {
+	golden, err := os.ReadFile("testdata/null.arrow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteArrowStream(&buf, goldenArrowColumns()); err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("%#v", goldenArrowColumns())
+	if actual := fmt.Sprintf("%#v", readArrowColumns(t, golden)); actual != expected {
+		t.Errorf("golden stream: actual and expected do not match. Actual: %v. Expected: %v.", actual, expected)
+	}
+	if actual := fmt.Sprintf("%#v", readArrowColumns(t, buf.Bytes())); actual != expected {
+		t.Errorf("WriteArrowStream: actual and expected do not match. Actual: %v. Expected: %v.", actual, expected)
+	}
+}
+
+func TestWriteNullArrow(t *testing.T) {
+	counts := testComparison().IterCounts
+	var buf bytes.Buffer
+	if err := WriteNullArrow(&buf, counts); err != nil {
+		t.Fatal(err)
+	}
+	stream := buf.Bytes()
+
+	r, root, body, stream := readArrowMessage(t, stream)
+	if r.buf[r.field(root, 1)] != arrowHeaderSchema || len(body) != 0 {
+		t.Fatalf("first message is not a schema")
+	}
+	schema := r.deref(r.field(root, 2))
+	fields := r.deref(r.field(schema, 1))
+	names := []string{"iteration", "name", "n_components", "count", "covered"}
+	if r.u32(fields) != len(names) {
+		t.Fatalf("%v fields, expected %v", r.u32(fields), len(names))
+	}
+	for i, name := range names {
+		f := r.deref(fields + 4 + 4 * i)
+		if r.str(r.field(f, 0)) != name {
+			t.Errorf("field %v is named %v, expected %v", i, r.str(r.field(f, 0)), name)
+		}
+		typ := int(r.buf[r.field(f, 2)])
+		if (name == "name" && typ != arrowTypeUtf8) || (name != "name" && typ != arrowTypeInt) {
+			t.Errorf("field %v has type %v", name, typ)
+		}
+		if name != "name" {
+			intType := r.deref(r.field(f, 3))
+			if r.u32(r.field(intType, 0)) != 64 || r.buf[r.field(intType, 1)] != 1 {
+				t.Errorf("field %v is not a signed 64-bit int", name)
+			}
+		}
+		if r.field(f, 5) < 0 {
+			t.Errorf("field %v has no children vector", name)
+		}
+	}
+
+	r, root, body, stream = readArrowMessage(t, stream)
+	if r.buf[r.field(root, 1)] != arrowHeaderRecordBatch {
+		t.Fatalf("second message is not a record batch")
+	}
+	batch := r.deref(r.field(root, 2))
+	nrows := 2 * len(counts)
+	if n := int(r.i64(r.field(batch, 0))); n != nrows {
+		t.Errorf("batch has %v rows, expected %v", n, nrows)
+	}
+	buffers := r.deref(r.field(batch, 2))
+	if (buffers + 4) % 8 != 0 {
+		t.Errorf("buffer structs are not 8-aligned")
+	}
+	if r.u32(buffers) != 2 + 3 + 2 + 2 + 2 {
+		t.Fatalf("%v buffers", r.u32(buffers))
+	}
+	buffer := func(i int) []byte {
+		p := buffers + 4 + 16 * i
+		off, n := int(r.i64(p)), int(r.i64(p + 8))
+		if off % 8 != 0 {
+			t.Errorf("buffer %v is not 8-aligned", i)
+		}
+		return body[off : off + n]
+	}
+	// name offsets and data, then count values
+	offsets, data, countData := buffer(3), buffer(4), buffer(8)
+	for row := 0; row < nrows; row++ {
+		c := counts[row / 2]
+		start, end := binary.LittleEndian.Uint32(offsets[4 * row:]), binary.LittleEndian.Uint32(offsets[4 * row + 4:])
+		if name := string(data[start:end]); name != c.Name {
+			t.Errorf("row %v name %q, expected %q", row, name, c.Name)
+		}
+		if n := int(binary.LittleEndian.Uint64(countData[8 * row:])); n != c.Count[row % 2] {
+			t.Errorf("row %v count %v, expected %v", row, n, c.Count[row % 2])
+		}
+	}
+
+	if !bytes.Equal(stream, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}) {
+		t.Errorf("stream does not end with the end-of-stream marker: %v", stream)
+	}
+}
@@ -0,0 +1,217 @@
+package permuvals
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+)
+
+// A rand.Source that counts how many values have been drawn from it, so that
+// a new source with the same seed can be brought to the same state
+type CountingSource struct {
+	src rand.Source64
+	Draws uint64
+}
+
+func NewCountingSource(seed int64) *CountingSource {
+	return &CountingSource{src: rand.NewSource(seed).(rand.Source64)}
+}
+
+func (s *CountingSource) Int63() int64 {
+	s.Draws++
+	return s.src.Int63()
+}
+
+func (s *CountingSource) Uint64() uint64 {
+	s.Draws++
+	return s.src.Uint64()
+}
+
+func (s *CountingSource) Seed(seed int64) {
+	s.Draws = 0
+	s.src.Seed(seed)
+}
+
+// Draw and discard n values
+func (s *CountingSource) Skip(n uint64) {
+	for i := uint64(0); i < n; i++ {
+		s.Int63()
+	}
+}
+
+// The state of a permutation run after Iteration permutations
+type Checkpoint struct {
+	Meta RunMeta `json:"meta"`
+	Labels []string `json:"labels"`
+	Iteration int `json:"iteration"`
+	Draws uint64 `json:"rng_draws"`
+	Counts OverlapCounts `json:"counts"`
+}
+
+// Where and how often to save checkpoints
+type CheckpointOpts struct {
+	Path string
+	// Iterations between checkpoints
+	Every int
+	// Continue from the checkpoint at Path, if there is one
+	Resume bool
+}
+
+func bedLabels(beds Beds) []string {
+	labels := make([]string, 0, len(beds))
+	for _, b := range beds {
+		labels = append(labels, b.Name)
+	}
+	return labels
+}
+
+func sameInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSubsets(a, b [][]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !sameStrings(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check that ck was made by a run with the same inputs and settings as meta,
+// other than the number of iterations. The beds and genome themselves are
+// compared through the InputHash.
+func (ck Checkpoint) Matches(meta RunMeta, labels []string) error {
+	m := ck.Meta
+	switch {
+	case m.Rseed != meta.Rseed:
+		return fmt.Errorf("checkpoint seed %v does not match %v", m.Rseed, meta.Rseed)
+	case m.MaxComps != meta.MaxComps:
+		return fmt.Errorf("checkpoint max comparisons %v does not match %v", m.MaxComps, meta.MaxComps)
+	case m.Min != meta.Min:
+		return fmt.Errorf("checkpoint minimum overlap %+v does not match %+v", m.Min, meta.Min)
+	case !sameSubsets(m.Subsets, meta.Subsets):
+		return fmt.Errorf("checkpoint subsets %v do not match %v", m.Subsets, meta.Subsets)
+	case m.Exclusive != meta.Exclusive:
+		return fmt.Errorf("checkpoint exclusive overlaps %v does not match %v", m.Exclusive, meta.Exclusive)
+	case m.Window != meta.Window || !sameInts(m.WindowBeds, meta.WindowBeds):
+		return fmt.Errorf("checkpoint window %v around beds %v does not match %v around %v", m.Window, m.WindowBeds, meta.Window, meta.WindowBeds)
+	case !sameInts(m.ToPermute, meta.ToPermute):
+		return fmt.Errorf("checkpoint beds to permute %v do not match %v", m.ToPermute, meta.ToPermute)
+	case m.Distance != meta.Distance:
+		return fmt.Errorf("checkpoint distances %v does not match %v", m.Distance, meta.Distance)
+	case m.Pairwise != meta.Pairwise:
+		return fmt.Errorf("checkpoint pairwise %v does not match %v", m.Pairwise, meta.Pairwise)
+	case m.GenomePath != meta.GenomePath:
+		return fmt.Errorf("checkpoint genome %v does not match %v", m.GenomePath, meta.GenomePath)
+	case m.GenomeFormat != meta.GenomeFormat:
+		return fmt.Errorf("checkpoint genome format %v does not match %v", m.GenomeFormat, meta.GenomeFormat)
+	case m.MinGap != meta.MinGap || m.ChromExclude != meta.ChromExclude:
+		return fmt.Errorf("checkpoint genome gaps %v and excluded chromosomes %q do not match %v and %q", m.MinGap, m.ChromExclude, meta.MinGap, meta.ChromExclude)
+	case !sameStrings(ck.Labels, labels):
+		return fmt.Errorf("checkpoint beds %v do not match %v", ck.Labels, labels)
+	case m.InputHash != meta.InputHash:
+		return fmt.Errorf("checkpoint input hash %q does not match %q; a bed or the genome has changed", m.InputHash, meta.InputHash)
+	case ck.Iteration > meta.Iterations:
+		return fmt.Errorf("checkpoint has %v iterations, more than the %v requested", ck.Iteration, meta.Iterations)
+	}
+	return nil
+}
+
+func ReadCheckpoint(r io.Reader) (ck Checkpoint, err error) {
+	err = json.NewDecoder(r).Decode(&ck)
+	return
+}
+
+// Read the checkpoint at path; ok is false if there is none
+func GetCheckpoint(path string) (ck Checkpoint, ok bool, err error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ck, false, nil
+	}
+	if err != nil { return }
+	defer f.Close()
+	ck, err = ReadCheckpoint(f)
+	return ck, err == nil, err
+}
+
+// Write ck to path, replacing any previous checkpoint only once the new one
+// is complete
+func WriteCheckpoint(path string, ck Checkpoint) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path) + ".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(ck); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Run meta.Iterations permutations like Permutations, keeping only their
+// counts, and saving a checkpoint every opts.Every iterations and at the end.
+// With opts.Resume, the run continues from the last checkpoint, and the
+// result is identical to an uninterrupted run with the same seed.
+func CheckpointedCounts(beds Beds, genome Bed, meta RunMeta, opts CheckpointOpts) (counts OverlapCounts, err error) {
+	labels := bedLabels(beds)
+	src := NewCountingSource(int64(meta.Rseed))
+	start := 0
+	if opts.Resume {
+		ck, ok, err := GetCheckpoint(opts.Path)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if err := ck.Matches(meta, labels); err != nil {
+				return nil, err
+			}
+			src.Skip(ck.Draws)
+			Log().Infof("resuming from iteration %v of %v in %v", ck.Iteration, meta.Iterations, opts.Path)
+			counts, start = ck.Counts, ck.Iteration
+		}
+	}
+
+	randgen := rand.New(src)
+	for i := start; i < meta.Iterations; i++ {
+		counts = AddPermutationCounts(counts, PermuteOpts(beds, genome, randgen, meta.ToPermute, meta.OverlapOpts))
+		if done := i + 1; done == meta.Iterations || (opts.Every > 0 && done % opts.Every == 0) {
+			ck := Checkpoint{Meta: meta, Labels: labels, Iteration: done, Draws: src.Draws, Counts: counts}
+			if err = WriteCheckpoint(opts.Path, ck); err != nil { return }
+			Log().Infof("checkpoint at iteration %v of %v written to %v", done, meta.Iterations, opts.Path)
+		}
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package permuvals
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func checkpointInputs() (Beds, Bed) {
+	// leave out the chromosome of in2Bspans that is not in the genome
+	beds := Beds{toBed("first", in1Bspans()), toBed("second", in2Bspans()[:3])}
+	return beds, toBed("genome", genomeBspans())
+}
+
+func checkCountsEqual(t *testing.T, actual, expected OverlapCounts) {
+	if len(actual) != len(expected) {
+		t.Fatalf("%v counts, expected %v", len(actual), len(expected))
+	}
+	for i := range actual {
+		if actual[i].Name != expected[i].Name || !sameInts(actual[i].Count, expected[i].Count) || !sameInts(actual[i].Covered, expected[i].Covered) {
+			t.Errorf("counts %v do not match %v", actual[i], expected[i])
+		}
+	}
+}
+
+func TestCheckpointResume(t *testing.T) {
+	beds, genome := checkpointInputs()
+	meta := RunMeta{Iterations: 25, Rseed: 7, OverlapOpts: OverlapOpts{MaxComps: -1}}
+	expected := CountPermutations(Permutations(beds, genome, meta.Iterations, rand.New(rand.NewSource(7)), -1, nil))
+
+	path := filepath.Join(t.TempDir(), "ck.json")
+	opts := CheckpointOpts{Path: path, Every: 4}
+
+	// stand in for a run interrupted after 10 iterations
+	partial := meta
+	partial.Iterations = 10
+	if _, err := CheckpointedCounts(beds, genome, partial, opts); err != nil {
+		t.Fatal(err)
+	}
+	ck, ok, err := GetCheckpoint(path)
+	if err != nil || !ok || ck.Iteration != 10 {
+		t.Fatalf("checkpoint %v, %v, %v", ck.Iteration, ok, err)
+	}
+
+	opts.Resume = true
+	counts, err := CheckpointedCounts(beds, genome, meta, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCountsEqual(t, counts, expected)
+
+	// resuming a finished run just returns its counts
+	counts, err = CheckpointedCounts(beds, genome, meta, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkCountsEqual(t, counts, expected)
+
+	other := meta
+	other.Rseed = 8
+	if _, err := CheckpointedCounts(beds, genome, other, opts); err == nil {
+		t.Errorf("resuming with a different seed should fail")
+	}
+	other = meta
+	other.MinGap = 10
+	if _, err := CheckpointedCounts(beds, genome, other, opts); err == nil {
+		t.Errorf("resuming with a different -gn should fail")
+	}
+	other = meta
+	other.Distance = true
+	if _, err := CheckpointedCounts(beds, genome, other, opts); err == nil {
+		t.Errorf("resuming with -distance should fail")
+	}
+}
+
+func TestCheckpointChangedBed(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	flags := Flags{BedArgs: []string{a, b}, GenomeBedPath: genome, Iterations: 5, Rseed: 3}
+	flags.MaxComps = 4
+	flags.Checkpoint = CheckpointOpts{Path: filepath.Join(t.TempDir(), "ck.json"), Every: 1}
+	if _, err := FullCompare(flags); err != nil {
+		t.Fatal(err)
+	}
+	flags.Iterations = 10
+	flags.Checkpoint.Resume = true
+	if _, err := FullCompare(flags); err != nil {
+		t.Fatalf("resuming with the same beds failed: %v", err)
+	}
+
+	// same labels and settings, but one more span
+	if err := os.WriteFile(a, []byte("one\t2\t7\none\t99\t110\ntwo\t0\t11\ntwo\t50\t60\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FullCompare(flags); err == nil {
+		t.Errorf("resuming after a bed changed should fail")
+	}
+}
@@ -0,0 +1,496 @@
+package permuvals
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+)
+
+// A subcommand of the permuvals command line
+type Command struct {
+	Name string
+	// One line, shown in the list of commands
+	Summary string
+	// Usage line following the command name
+	Args string
+	// Register flags on fs; the returned function runs the command after
+	// fs has been parsed
+	Setup func(fs *flag.FlagSet, stdout io.Writer) func() error
+}
+
+// An error in the command line itself, reported with exit status 2
+type UsageError struct {
+	Err error
+}
+
+func (e UsageError) Error() string { return e.Err.Error() }
+func (e UsageError) Unwrap() error { return e.Err }
+
+func usageErrorf(format string, args ...interface{}) error {
+	return UsageError{fmt.Errorf(format, args...)}
+}
+
+// All subcommands, in the order they are listed in the help
+var Commands = []Command {
+	{"test", "Permute bed spans and test whether their overlaps are more than expected by chance", "[flags] [bed | label=bed ...]", setupTest},
+	{"overlap", "Print the overlaps between every combination of beds, without permuting", "[flags] [bed | label=bed ...]", setupOverlap},
+	{"intersect", "Print the bases covered by every bed", "a.bed b.bed...", setupIntersect},
+	{"subtract", "Remove the spans of the other beds from the first bed", "[flags] a.bed b.bed...", setupSubtract},
+	{"union", "Print the bases covered by any bed, merging overlapping spans", "bed...", setupUnion},
+	{"complement", "Print the bases of the genome not covered by any bed", "[flags] bed...", setupComplement},
+	{"slop", "Extend every span of a bed, clipped to the chromosome ends", "[flags] bed", setupSlop},
+	{"flank", "Print the regions next to every span of a bed, clipped to the chromosome ends", "[flags] bed", setupFlank},
+	{"shuffle", "Write randomly placed copies of beds, as test permutes them", "[flags] [bed | label=bed ...]", setupShuffle},
+	{"merge", "Combine the null distributions of shards written by test -shard", "[flags] shard.json...", setupMerge},
+	{"matrix", "Print the pairwise enrichments of a JSON comparison as a matrix, and draw a heatmap", "[flags] comparison.json", setupMatrix},
+}
+
+// Register the flags that choose the input beds
+func AddBedFlags(fs *flag.FlagSet, f *Flags) {
+	fs.StringVar(&f.BedPaths, "b", "", "File listing all bed files to compare: one path per line, or a manifest table")
+	fs.IntVar(&f.MaxComps, "m", 4, "Maximum number of beds to compare at once")
+	fs.Func("subsets", "comma-separated list of subsets to compare instead of every subset of up to -m beds, each as colon-separated labels (e.g. A:B,A:C)", func(s string) error {
+		f.Subsets = append(f.Subsets, ParseSubsetList(s)...)
+		return nil
+	})
+	fs.StringVar(&f.SubsetsPath, "subsets-file", "", "File listing subsets to compare, one per line as colon- or tab-separated labels")
+	fs.StringVar(&f.Reference, "reference", "", "Compare each other bed with the bed of this label, instead of every subset")
+	AddMinOverlapFlags(fs, &f.Min)
+	fs.IntVar(&f.Window, "window", 0, "Pad the spans of the -window-beds by this many bp on each side before finding overlaps, to test proximity")
+	fs.Func("window-beds", "comma-separated list of 0-indexed indices of beds to pad by -window (default all)", func(s string) (err error) {
+		f.WindowBeds, err = parseIndices(s)
+		return
+	})
+	fs.BoolVar(&f.Exclusive, "exclusive", false, "Count only the bases of each subset that no other bed covers, so that the subsets partition the beds")
+	fs.BoolVar(&f.Distance, "distance", false, "Also find the distance from each span to the nearest span of each other bed, and test the mean, median and relative distance")
+}
+
+// Register the flags that set how much spans must overlap to count
+func AddMinOverlapFlags(fs *flag.FlagSet, m *MinOverlap) {
+	fs.IntVar(&m.Bp, "min-bp", 0, "Only count overlaps of at least this many bp")
+	fs.Float64Var(&m.FracA, "min-frac-a", 0, "Only count overlaps covering at least this fraction of the span of the first bed")
+	fs.Float64Var(&m.FracB, "min-frac-b", 0, "Only count overlaps covering at least this fraction of the span of each other bed")
+	fs.BoolVar(&m.Reciprocal, "reciprocal", false, "Require -min-frac-a of the spans of the other beds too")
+}
+
+func checkOverlapOpts(o OverlapOpts) error {
+	if o.Window < 0 {
+		return usageErrorf("-window must not be negative")
+	}
+	return checkMinOverlap(o.Min)
+}
+
+func checkMinOverlap(m MinOverlap) error {
+	if m.FracA < 0 || m.FracA > 1 || m.FracB < 0 || m.FracB > 1 {
+		return usageErrorf("-min-frac-a and -min-frac-b must be between 0 and 1")
+	}
+	return nil
+}
+
+// Register the flags that control diagnostic messages, which are written to
+// stderr; see LoggerFromFlags
+func AddLogFlags(fs *flag.FlagSet, f *Flags) {
+	fs.BoolVar(&f.Verbose, "v", false, "Print much more information while running (same as -log-level debug)")
+	fs.StringVar(&f.LogLevel, "log-level", LogWarn.String(), "Least important diagnostic messages to print: debug, info, warn, error, or off")
+}
+
+// Register the flags that choose the genome
+func AddGenomeFlags(fs *flag.FlagSet, f *Flags) {
+	fs.StringVar(&f.GenomeBedPath, "g", "", "Bed file containing the lengths of all chromosomes")
+	fs.StringVar(&f.GenomeFormat, "gf", GenomeAuto, "Format of the -g file: auto, bed, sizes (chrom.sizes), fai, or fasta")
+	fs.IntVar(&f.MinGap, "gn", 0, "With a FASTA genome, leave out runs of more than this many Ns (default 0, keep all)")
+	fs.StringVar(&f.ChromExclude, "gx", "", "Regular expression; genome chromosomes matching it are not used")
+}
+
+// Register the flags that control permutation
+func AddPermuteFlags(fs *flag.FlagSet, f *Flags) {
+	fs.IntVar(&f.Iterations, "i", -1, "Number of permutation iterations to perform")
+	AddSeedFlags(fs, f)
+	fs.StringVar(&f.Checkpoint.Path, "checkpoint", "", "Save the permutation counts and random number state to this file as the run goes")
+	fs.IntVar(&f.Checkpoint.Every, "checkpoint-every", 1000, "Iterations between checkpoints")
+	fs.BoolVar(&f.Checkpoint.Resume, "resume", false, "Continue from the -checkpoint file if it exists")
+	fs.BoolVar(&f.Pairwise, "pairwise", false, "Instead of testing every subset, test each bed of -p against each other bed by permuting it alone")
+}
+
+// Register the flags that choose the random seed and which beds are permuted
+func AddSeedFlags(fs *flag.FlagSet, f *Flags) {
+	fs.IntVar(&f.Rseed, "r", 0, "Random seed for permutations (default 0)")
+	fs.Func("p", "comma-separated list of 0-indexed indices of beds to permute, counting -b beds then positional beds (default all)", func(s string) (err error) {
+		f.ToPermute, err = parseIndices(s)
+		return
+	})
+}
+
+// Register the flags that say where to write a Comparison; see WriteOutputs
+func AddOutputFlags(fs *flag.FlagSet, f *Flags) {
+	fs.BoolVar(&f.CountsPrint, "c", false, "Output raw overlap counts from each permutation")
+	fs.StringVar(&f.OutFormat, "format", OutTSV, "Output format: tsv, json, or jsonl")
+	fs.StringVar(&f.OutPrefix, "o", "", "Write overlaps.bed, probs.tsv, null_counts.tsv and meta.json with this prefix instead of printing to stdout")
+	fs.BoolVar(&f.OutArrow, "arrow", false, "With -o, also write the null distribution as null_counts.arrow (Arrow IPC stream)")
+	fs.StringVar(&f.ShardPath, "shard", "", "Write the comparison as JSON to this file, for combining with the merge command, instead of printing it")
+	fs.StringVar(&f.ReportPath, "html", "", "Also write an HTML report with plots of the null distributions to this path")
+}
+
+func checkOutputFlags(f Flags) error {
+	if f.ShardPath != "" && (f.OutPrefix != "" || f.OutFormat != OutTSV || f.CountsPrint) {
+		return usageErrorf("-shard writes only the shard, so it cannot be used with -o, -format or -c")
+	}
+	switch f.OutFormat {
+	case OutTSV, OutJSON, OutJSONL:
+		return nil
+	}
+	return usageErrorf("unknown output format %q", f.OutFormat)
+}
+
+// Parse the flags of a permutation test
+func ParseTestFlags(fs *flag.FlagSet, args []string) (f Flags, err error) {
+	AddLogFlags(fs, &f)
+	run := setupTestFlags(fs, &f)
+	if err = fs.Parse(args); err != nil { return }
+	err = run()
+	return
+}
+
+func setupTestFlags(fs *flag.FlagSet, f *Flags) func() error {
+	AddBedFlags(fs, f)
+	AddGenomeFlags(fs, f)
+	AddPermuteFlags(fs, f)
+	AddOutputFlags(fs, f)
+	return func() error {
+		f.BedArgs = fs.Args()
+		if f.BedPaths == "" && len(f.BedArgs) < 1 {
+			return usageErrorf("no beds given; use -b or list them as arguments")
+		}
+		if f.GenomeBedPath == "" {
+			return usageErrorf("missing genome; use -g")
+		}
+		if err := checkOverlapOpts(f.OverlapOpts); err != nil {
+			return err
+		}
+		if f.Distance && f.Checkpoint.Path != "" {
+			return usageErrorf("-distance cannot be used with -checkpoint")
+		}
+		if f.Pairwise && (f.Distance || f.Checkpoint.Path != "" || f.Exclusive) {
+			return usageErrorf("-pairwise cannot be used with -distance, -checkpoint or -exclusive")
+		}
+		if f.Pairwise && (len(f.Subsets) > 0 || f.SubsetsPath != "" || f.Reference != "") {
+			return usageErrorf("-pairwise cannot be used with -subsets, -subsets-file or -reference")
+		}
+		return checkOutputFlags(*f)
+	}
+}
+
+func setupTest(fs *flag.FlagSet, stdout io.Writer) func() error {
+	var f Flags
+	check := setupTestFlags(fs, &f)
+	return func() error {
+		if err := check(); err != nil {
+			return err
+		}
+		c, err := FullCompare(f)
+		if err != nil {
+			return err
+		}
+		return WriteOutputs(stdout, f, c)
+	}
+}
+
+func setupOverlap(fs *flag.FlagSet, stdout io.Writer) func() error {
+	var f Flags
+	AddBedFlags(fs, &f)
+	// only needed to clip -window padding to the chromosome ends
+	AddGenomeFlags(fs, &f)
+	fs.StringVar(&f.OutFormat, "format", OutTSV, "Output format: tsv, json, or jsonl")
+	return func() error {
+		f.BedArgs = fs.Args()
+		if f.BedPaths == "" && len(f.BedArgs) < 1 {
+			return usageErrorf("no beds given; use -b or list them as arguments")
+		}
+		if f.Window != 0 && f.GenomeBedPath == "" {
+			return usageErrorf("-window needs the genome to clip padding to; use -g")
+		}
+		if err := checkOverlapOpts(f.OverlapOpts); err != nil {
+			return err
+		}
+		if err := checkOutputFlags(f); err != nil {
+			return err
+		}
+		c, err := FullCompare(f)
+		if err != nil {
+			return err
+		}
+		return WriteOutputs(stdout, f, c)
+	}
+}
+
+// Read the beds named in args, of which there must be at least min
+func argBeds(args []string, min int) (beds Beds, err error) {
+	m, err := ManifestFromArgs(args)
+	if err != nil {
+		return nil, UsageError{err}
+	}
+	if len(m) < min {
+		return nil, usageErrorf("need at least %v beds", min)
+	}
+	return m.Beds()
+}
+
+// Print a bed, buffered
+func printBed(stdout io.Writer, b Bed) error {
+	w := bufio.NewWriter(stdout)
+	WriteBeds(w, b)
+	return w.Flush()
+}
+
+func setupIntersect(fs *flag.FlagSet, stdout io.Writer) func() error {
+	return func() error {
+		beds, err := argBeds(fs.Args(), 2)
+		if err != nil {
+			return err
+		}
+		return printBed(stdout, IntersectBeds(beds...))
+	}
+}
+
+func setupSubtract(fs *flag.FlagSet, stdout io.Writer) func() error {
+	full := fs.Bool("full", false, "Remove whole spans of the first bed that overlap the others, rather than just the overlapping bases")
+	var min MinOverlap
+	AddMinOverlapFlags(fs, &min)
+	return func() error {
+		if err := checkMinOverlap(min); err != nil {
+			return err
+		}
+		beds, err := argBeds(fs.Args(), 2)
+		if err != nil {
+			return err
+		}
+		if *full {
+			return printBed(stdout, SubtractFullBeds(beds[0], min, beds[1:]...))
+		}
+		return printBed(stdout, SubtractBeds(beds[0], beds[1:]...))
+	}
+}
+
+func setupUnion(fs *flag.FlagSet, stdout io.Writer) func() error {
+	return func() error {
+		beds, err := argBeds(fs.Args(), 1)
+		if err != nil {
+			return err
+		}
+		return printBed(stdout, UnionBeds("union", beds...))
+	}
+}
+
+func setupComplement(fs *flag.FlagSet, stdout io.Writer) func() error {
+	var f Flags
+	AddGenomeFlags(fs, &f)
+	return func() error {
+		if f.GenomeBedPath == "" {
+			return usageErrorf("missing genome; use -g")
+		}
+		beds, err := argBeds(fs.Args(), 1)
+		if err != nil {
+			return err
+		}
+		genome, err := GetFlagsGenome(f)
+		if err != nil {
+			return err
+		}
+		return printBed(stdout, UnionBeds("union", beds...).Complement(genome))
+	}
+}
+
+// Register the flags of slop and flank, and return a function that reads the
+// genome and bed and gives the bp to add on each side
+func setupSides(fs *flag.FlagSet) func() (genome, bed Bed, left, right int, err error) {
+	var f Flags
+	AddGenomeFlags(fs, &f)
+	both := fs.Int("both", 0, "Bases to add on both sides")
+	l := fs.Int("l", 0, "Bases to add before the start of each span, if more than -both")
+	r := fs.Int("r", 0, "Bases to add after the end of each span, if more than -both")
+	return func() (genome, bed Bed, left, right int, err error) {
+		if f.GenomeBedPath == "" {
+			err = usageErrorf("missing genome; use -g")
+			return
+		}
+		if *both < 0 || *l < 0 || *r < 0 {
+			err = usageErrorf("-both, -l and -r must not be negative")
+			return
+		}
+		if fs.NArg() != 1 {
+			err = usageErrorf("need exactly one bed")
+			return
+		}
+		left, right = *both, *both
+		if *l > left { left = *l }
+		if *r > right { right = *r }
+		beds, err := argBeds(fs.Args(), 1)
+		if err != nil { return }
+		genome, err = GetFlagsGenome(f)
+		return genome, beds[0], left, right, err
+	}
+}
+
+func setupSlop(fs *flag.FlagSet, stdout io.Writer) func() error {
+	sides := setupSides(fs)
+	return func() error {
+		genome, bed, left, right, err := sides()
+		if err != nil {
+			return err
+		}
+		return printBed(stdout, bed.Slop(genome, left, right))
+	}
+}
+
+func setupFlank(fs *flag.FlagSet, stdout io.Writer) func() error {
+	sides := setupSides(fs)
+	return func() error {
+		genome, bed, left, right, err := sides()
+		if err != nil {
+			return err
+		}
+		return printBed(stdout, bed.Flank(genome, left, right))
+	}
+}
+
+func setupShuffle(fs *flag.FlagSet, stdout io.Writer) func() error {
+	var f Flags
+	fs.StringVar(&f.BedPaths, "b", "", "File listing all bed files to shuffle: one path per line, or a manifest table")
+	AddGenomeFlags(fs, &f)
+	AddSeedFlags(fs, &f)
+	replicates := fs.Int("n", 1, "Number of shuffled copies of each bed to write")
+	fs.StringVar(&f.OutPrefix, "o", "", "Write each copy to <prefix><label>_<replicate>.bed instead of printing all of them to stdout")
+	return func() error {
+		f.BedArgs = fs.Args()
+		if f.BedPaths == "" && len(f.BedArgs) < 1 {
+			return usageErrorf("no beds given; use -b or list them as arguments")
+		}
+		if f.GenomeBedPath == "" {
+			return usageErrorf("missing genome; use -g")
+		}
+		if *replicates < 1 {
+			return usageErrorf("-n must be at least 1")
+		}
+		return FullShuffle(stdout, f, *replicates)
+	}
+}
+
+func setupMerge(fs *flag.FlagSet, stdout io.Writer) func() error {
+	var f Flags
+	AddOutputFlags(fs, &f)
+	return func() error {
+		if fs.NArg() < 1 {
+			return usageErrorf("no shards given")
+		}
+		if err := checkOutputFlags(f); err != nil {
+			return err
+		}
+		return FullMerge(stdout, f, fs.Args())
+	}
+}
+
+func setupMatrix(fs *flag.FlagSet, stdout io.Writer) func() error {
+	stat := fs.String("stat", "covered_fold", "Statistic to print: count_fold, covered_fold, count_prob, or covered_prob")
+	cluster := fs.Bool("cluster", false, "Order the rows and columns by hierarchical clustering of their coverage fold enrichments")
+	svgPath := fs.String("svg", "", "Also draw a heatmap of the coverage fold enrichments to this SVG file")
+	return func() error {
+		if fs.NArg() != 1 {
+			return usageErrorf("need exactly one comparison")
+		}
+		c, err := ReadComparisonJSONPath(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		m := NewPairMatrix(c)
+		if *cluster {
+			m = m.Clustered()
+		}
+		values, err := m.Stat(*stat)
+		if err != nil {
+			return UsageError{err}
+		}
+		if *svgPath != "" {
+			err = writeFile(*svgPath, func(w io.Writer) error {
+				_, err := io.WriteString(w, string(HeatmapSVG("pairwise enrichment", m)))
+				return err
+			})
+			if err != nil {
+				return err
+			}
+		}
+		w := bufio.NewWriter(stdout)
+		FprintMatrixTSV(w, m.Rows, m.Cols, values)
+		return w.Flush()
+	}
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Usage: permuvals <command> [flags] [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, c := range Commands {
+		fmt.Fprintf(w, "  %-11v %v\n", c.Name, c.Summary)
+	}
+	fmt.Fprintln(w, "\nRun \"permuvals <command> -h\" for the flags of a command.")
+}
+
+// Run the permuvals command line. args does not include the program name.
+// Returns the exit status: 0 on success, 1 if the command failed, and 2 if
+// the command line was wrong.
+func Main(args []string, stdout, stderr io.Writer) int {
+	if len(args) < 1 {
+		printCommands(stderr)
+		return 2
+	}
+	if name := args[0]; name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		printCommands(stdout)
+		return 0
+	}
+
+	for _, c := range Commands {
+		if c.Name != args[0] {
+			continue
+		}
+		fs := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+		fs.SetOutput(stderr)
+		fs.Usage = func() {
+			fmt.Fprintf(fs.Output(), "Usage: permuvals %v %v\n\n%v\n\n", c.Name, c.Args, c.Summary)
+			fs.PrintDefaults()
+		}
+		var logFlags Flags
+		AddLogFlags(fs, &logFlags)
+		run := c.Setup(fs, stdout)
+		if err := fs.Parse(args[1:]); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return 0
+			}
+			return 2
+		}
+		logger, err := LoggerFromFlags(stderr, logFlags)
+		if err != nil {
+			fmt.Fprintf(stderr, "permuvals %v: %v\n", c.Name, err)
+			fs.Usage()
+			return 2
+		}
+		defer SetLogger(Log())
+		SetLogger(logger)
+
+		err = run()
+		var uerr UsageError
+		if errors.As(err, &uerr) {
+			fmt.Fprintf(stderr, "permuvals %v: %v\n", c.Name, err)
+			fs.Usage()
+			return 2
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "permuvals %v: %v\n", c.Name, err)
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintf(stderr, "permuvals: unknown command %q\n\n", args[0])
+	printCommands(stderr)
+	return 2
+}
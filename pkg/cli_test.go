@@ -0,0 +1,280 @@
+package permuvals
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestBeds(t *testing.T) (a, b, genome string) {
+	dir := t.TempDir()
+	a, b, genome = filepath.Join(dir, "a.bed"), filepath.Join(dir, "b.bed"), filepath.Join(dir, "g.sizes")
+	files := map[string]string {
+		a: "one\t2\t7\none\t99\t110\ntwo\t0\t11\n",
+		b: "one\t5\t22\none\t80\t105\ntwo\t3\t20\n",
+		genome: "one\t200\ntwo\t300\n",
+	}
+	for path, data := range files {
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return
+}
+
+func runMain(args ...string) (int, string, string) {
+	var stdout, stderr strings.Builder
+	status := Main(args, &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestMainStatus(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	// too small for any span of b
+	tiny := filepath.Join(t.TempDir(), "tiny.sizes")
+	if err := os.WriteFile(tiny, []byte("one\t12\ntwo\t15\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		args []string
+		status int
+	} {
+		{nil, 2},
+		{[]string{"help"}, 0},
+		{[]string{"nonsense"}, 2},
+		{[]string{"test", "-h"}, 0},
+		{[]string{"test", "-nonsense"}, 2},
+		{[]string{"test", a, b}, 2},
+		{[]string{"test", "-g", genome}, 2},
+		{[]string{"test", "-g", genome, "-format", "xml", a}, 2},
+		{[]string{"test", "-g", genome, "missing.bed"}, 1},
+		{[]string{"test", "-g", genome, "-i", "5", "A=" + a, "B=" + b}, 0},
+		{[]string{"test", "-g", genome, "-pairwise", "-exclusive", a}, 2},
+		{[]string{"test", "-g", tiny, "-i", "5", b, a}, 1},
+		{[]string{"test", "-g", tiny, b, a}, 0},
+		{[]string{"shuffle", "-g", tiny, b, a}, 1},
+		{[]string{"test", "-g", genome, "-i", "5", "-p", "1", a, b}, 0},
+		{[]string{"test", "-g", genome, "-p", "2", a, b}, 2},
+		{[]string{"test", "-g", genome, "-p", "-1", a, b}, 2},
+		{[]string{"test", "-g", genome, "-window", "5", "-window-beds", "0,2", a, b}, 2},
+		{[]string{"shuffle", "-g", genome, "-p", "2", a, b}, 2},
+		{[]string{"merge"}, 2},
+		{[]string{"overlap", "-window", "5", a, b}, 2},
+		{[]string{"overlap", "-g", genome, "-window", "5", a, b}, 0},
+		{[]string{"test", "-g", genome, "-shard", "s.json", "-o", "out_", a}, 2},
+		{[]string{"test", "-g", genome, "-shard", "s.json", "-format", "json", a}, 2},
+	}
+	for _, c := range cases {
+		if status, _, stderr := runMain(c.args...); status != c.status {
+			t.Errorf("%v: status %v, expected %v; stderr:\n%v", c.args, status, c.status, stderr)
+		}
+	}
+}
+
+func TestMainOverlapSubtract(t *testing.T) {
+	a, b, _ := writeTestBeds(t)
+	status, stdout, stderr := runMain("overlap", "A=" + a, "B=" + b)
+	if status != 0 {
+		t.Fatalf("overlap failed: %v", stderr)
+	}
+	if !strings.Contains(stdout, "one\t5\t7\t2\t2\t16\tA:B\n") {
+		t.Errorf("unexpected overlap output:\n%v", stdout)
+	}
+
+	status, stdout, stderr = runMain("overlap", "-min-bp", "6", "A=" + a, "B=" + b)
+	if status != 0 {
+		t.Fatalf("overlap -min-bp failed: %v", stderr)
+	}
+	if strings.Contains(stdout, "one\t5\t7\t") || !strings.Contains(stdout, "one\t99\t105\t2\t6\t14\tA:B\n") {
+		t.Errorf("unexpected overlap -min-bp output:\n%v", stdout)
+	}
+
+	status, stdout, stderr = runMain("overlap", "-exclusive", "A=" + a, "B=" + b)
+	if status != 0 {
+		t.Fatalf("overlap -exclusive failed: %v", stderr)
+	}
+	if !strings.Contains(stdout, "one\t2\t5\t1\t3\t11\tA\n") || !strings.Contains(stdout, "one\t5\t7\t2\t2\t16\tA:B\n") {
+		t.Errorf("unexpected overlap -exclusive output:\n%v", stdout)
+	}
+
+	status, stdout, stderr = runMain("subtract", a, b)
+	if status != 0 {
+		t.Fatalf("subtract failed: %v", stderr)
+	}
+	if expected := "one\t2\t5\none\t105\t110\ntwo\t0\t3\n"; stdout != expected {
+		t.Errorf("subtract output %q, expected %q", stdout, expected)
+	}
+}
+
+func TestMainSetOps(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	cases := []struct {
+		args []string
+		expected string
+	} {
+		{[]string{"intersect", a, b}, "one\t5\t7\none\t99\t105\ntwo\t3\t11\n"},
+		{[]string{"subtract", "-full", "-min-bp", "3", a, b}, "one\t2\t7\n"},
+		{[]string{"subtract", "-full", "-min-frac-a", "0.6", a, b}, "one\t2\t7\none\t99\t110\n"},
+		{[]string{"union", a, b}, "one\t2\t22\none\t80\t110\ntwo\t0\t20\n"},
+		{[]string{"complement", "-g", genome, a, b}, "one\t0\t2\none\t22\t80\none\t110\t200\ntwo\t20\t300\n"},
+		{[]string{"complement", "-g", genome, "-gx", "two", a}, "one\t0\t2\none\t7\t99\none\t110\t200\n"},
+	}
+	for _, c := range cases {
+		status, stdout, stderr := runMain(c.args...)
+		if status != 0 {
+			t.Errorf("%v failed: %v", c.args, stderr)
+		} else if stdout != c.expected {
+			t.Errorf("%v: output %q, expected %q", c.args, stdout, c.expected)
+		}
+	}
+	if status, _, _ := runMain("intersect", a); status != 2 {
+		t.Errorf("intersect of one bed: status %v, expected 2", status)
+	}
+	if status, _, _ := runMain("complement", a); status != 2 {
+		t.Errorf("complement without a genome: status %v, expected 2", status)
+	}
+}
+
+func TestMainSlopFlank(t *testing.T) {
+	a, _, genome := writeTestBeds(t)
+	cases := []struct {
+		args []string
+		expected string
+	} {
+		{[]string{"slop", "-g", genome, "-both", "5", a}, "one\t0\t12\none\t94\t115\ntwo\t0\t16\n"},
+		{[]string{"slop", "-g", genome, "-l", "1", "-r", "190", a}, "one\t1\t200\ntwo\t0\t201\n"},
+		{[]string{"flank", "-g", genome, "-r", "2", a}, "one\t7\t9\none\t110\t112\ntwo\t11\t13\n"},
+	}
+	for _, c := range cases {
+		status, stdout, stderr := runMain(c.args...)
+		if status != 0 {
+			t.Errorf("%v failed: %v", c.args, stderr)
+		} else if stdout != c.expected {
+			t.Errorf("%v: output %q, expected %q", c.args, stdout, c.expected)
+		}
+	}
+	if status, _, _ := runMain("slop", "-g", genome, "-both", "-1", a); status != 2 {
+		t.Errorf("negative slop: status %v, expected 2", status)
+	}
+}
+
+func TestMainLogging(t *testing.T) {
+	a, b, _ := writeTestBeds(t)
+	_, quiet, _ := runMain("overlap", "A=" + a, "B=" + b)
+	status, stdout, stderr := runMain("overlap", "-v", "A=" + a, "B=" + b)
+	if status != 0 {
+		t.Fatalf("overlap -v failed: %v", stderr)
+	}
+	if stdout != quiet {
+		t.Errorf("-v changed the output:\n%v\nexpected:\n%v", stdout, quiet)
+	}
+	if !strings.Contains(stderr, "debug: input A:") {
+		t.Errorf("-v did not log the inputs to stderr:\n%v", stderr)
+	}
+
+	status, stdout, stderr = runMain("subtract", "-full", "-log-level", "debug", a, b)
+	if status != 0 {
+		t.Fatalf("subtract -full failed: %v", stderr)
+	}
+	if stdout != "" {
+		t.Errorf("subtract -full output %q, expected nothing", stdout)
+	}
+
+	if status, _, _ := runMain("union", "-log-level", "loud", a); status != 2 {
+		t.Errorf("unknown log level: status %v, expected 2", status)
+	}
+}
+
+func TestMainWindowDistance(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	status, stdout, stderr := runMain("test", "-g", genome, "-i", "10", "-distance", "-window", "50", "-window-beds", "0", "A=" + a, "B=" + b)
+	if status != 0 {
+		t.Fatalf("test -distance failed: %v", stderr)
+	}
+	// padded by 50, A covers all of B
+	if !strings.Contains(stdout, "one\t5\t22\t2\t17\t59\tA:B\n") {
+		t.Errorf("-window did not pad A:\n%v", stdout)
+	}
+	if !strings.Contains(stdout, "\n" + DistancesHeader + "\n") {
+		t.Errorf("no distances header in:\n%v", stdout)
+	}
+	for _, name := range []string{"\tA->B\t3\t0\t0\t", "\tB->A\t3\t0\t0\t"} {
+		if !strings.Contains(stdout, name) {
+			t.Errorf("no distance line containing %q in:\n%v", name, stdout)
+		}
+	}
+	if status, _, _ := runMain("test", "-g", genome, "-distance", "-checkpoint", "x.ckpt", a); status != 2 {
+		t.Errorf("-distance with -checkpoint: status %v, expected 2", status)
+	}
+}
+
+func TestMainPairwise(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	status, stdout, stderr := runMain("test", "-g", genome, "-i", "10", "-pairwise", "-p", "1", "A=" + a, "B=" + b)
+	if status != 0 {
+		t.Fatalf("test -pairwise failed: %v", stderr)
+	}
+	// the subsets are not intersected, so the pairs come first
+	if !strings.HasPrefix(stdout, PairsHeader + "\n") {
+		t.Errorf("pairwise output does not start with its header:\n%v", stdout)
+	}
+	// B is the only query, and its overlap with A is 3 spans of 16 bp
+	if !strings.Contains(stdout, "\tB->A\t3\t16\t") || strings.Contains(stdout, "A->B") || strings.Contains(stdout, "A:B") {
+		t.Errorf("unexpected pairwise output:\n%v", stdout)
+	}
+	if status, _, _ := runMain("test", "-g", genome, "-pairwise", "-distance", a); status != 2 {
+		t.Errorf("-pairwise with -distance: status %v, expected 2", status)
+	}
+}
+
+func TestMainMatrix(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	dir := t.TempDir()
+	shard, svg := filepath.Join(dir, "c.json"), filepath.Join(dir, "m.svg")
+	if status, _, stderr := runMain("test", "-g", genome, "-i", "5", "-shard", shard, "A=" + a, "B=" + b); status != 0 {
+		t.Fatalf("test failed: %v", stderr)
+	}
+	status, stdout, stderr := runMain("matrix", "-cluster", "-stat", "count_prob", "-svg", svg, shard)
+	if status != 0 {
+		t.Fatalf("matrix failed: %v", stderr)
+	}
+	lines := strings.Split(stdout, "\n")
+	// the diagonal is untested
+	if len(lines) != 4 || (lines[0] != "name\tA\tB" && lines[0] != "name\tB\tA") || strings.Count(stdout, "NaN") != 2 {
+		t.Errorf("unexpected matrix:\n%v", stdout)
+	}
+	if data, err := os.ReadFile(svg); err != nil || !strings.Contains(string(data), "<svg") {
+		t.Errorf("no heatmap written: %v", err)
+	}
+	if status, _, _ := runMain("matrix", "-stat", "nonsense", shard); status != 2 {
+		t.Errorf("unknown statistic: status %v, expected 2", status)
+	}
+}
+
+func TestMainSubsets(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	c := filepath.Join(t.TempDir(), "c.bed")
+	if err := os.WriteFile(c, []byte("one\t0\t50\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, stderr := runMain("test", "-g", genome, "-i", "5", "-reference", "C", "A=" + a, "B=" + b, "C=" + c)
+	if status != 0 {
+		t.Fatalf("test -reference failed: %v", stderr)
+	}
+	// only C:A and C:B are tested, with the reference first
+	if probs := strings.Count(stdout, "\tC:A\n") + strings.Count(stdout, "\tC:B\n"); probs < 2 || strings.Contains(stdout, "A:B") {
+		t.Errorf("unexpected -reference output:\n%v", stdout)
+	}
+
+	status, stdout, stderr = runMain("overlap", "-subsets", "B:A", "A=" + a, "B=" + b, "C=" + c)
+	if status != 0 {
+		t.Fatalf("overlap -subsets failed: %v", stderr)
+	}
+	if !strings.Contains(stdout, "one\t5\t7\t2\t2\t16\tB:A\n") || strings.Contains(stdout, "C") {
+		t.Errorf("unexpected -subsets output:\n%v", stdout)
+	}
+	if status, _, _ := runMain("overlap", "-subsets", "A:D", "A=" + a, "B=" + b); status != 1 {
+		t.Errorf("unknown subset label: status %v, expected 1", status)
+	}
+}
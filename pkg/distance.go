@@ -0,0 +1,274 @@
+package permuvals
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/montanaflynn/stats"
+)
+
+// The spans of each chromosome of a bed in order, taken from its
+// per-chromosome intervalset.Set, for finding the spans nearest to others by
+// binary search
+type SpanIndex map[string][]Bspan
+
+func NewSpanIndex(b Bed) SpanIndex {
+	return SpanIndex(sortedBspans(b))
+}
+
+// The span of idx closest to span on the same chromosome, and the number of
+// bases between them: 0 if they overlap or adjoin. ok is false if there are
+// no spans on the chromosome.
+func (idx SpanIndex) Closest(span Bspan) (closest Bspan, dist int, ok bool) {
+	sorted := idx[span.Chrom]
+	if len(sorted) < 1 {
+		return closest, 0, false
+	}
+	// the first span ending after span starts either overlaps it or is the
+	// closest one after it; the one before it is the closest one before it
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Max > span.Min })
+	dist = math.MaxInt
+	if i < len(sorted) {
+		closest, dist = sorted[i], sorted[i].Min - span.Max
+		if dist < 0 {
+			dist = 0
+		}
+	}
+	if i > 0 {
+		if before := span.Min - sorted[i-1].Max; before < dist {
+			closest, dist = sorted[i-1], before
+		}
+	}
+	return closest, dist, true
+}
+
+func midpoint(span Bspan) float64 {
+	return float64(span.Min + span.Max) / 2
+}
+
+// The relative distance of span between the two spans of idx whose midpoints
+// are on either side of its midpoint, as in bedtools reldist: the distance to
+// the closer one over the distance between them, from 0 to 0.5. ok is false if
+// there is not a span on each side.
+func (idx SpanIndex) RelDist(span Bspan) (reldist float64, ok bool) {
+	sorted := idx[span.Chrom]
+	mid := midpoint(span)
+	// the spans do not overlap, so their midpoints are in order too
+	i := sort.Search(len(sorted), func(i int) bool { return midpoint(sorted[i]) > mid })
+	if i < 1 || i >= len(sorted) {
+		return 0, false
+	}
+	left, right := midpoint(sorted[i-1]), midpoint(sorted[i])
+	return math.Min(mid - left, right - mid) / (right - left), true
+}
+
+// A span of one bed and the closest span of another
+type ClosestPair struct {
+	A Bspan
+	B Bspan
+	Distance int
+}
+
+// For each span of a, the closest span of b on the same chromosome. Spans on
+// chromosomes without any span of b are left out.
+func ClosestSpans(a, b Bed) (pairs []ClosestPair) {
+	idx := NewSpanIndex(b)
+	for _, span := range AllBedSpans(a) {
+		if closest, dist, ok := idx.Closest(span); ok {
+			pairs = append(pairs, ClosestPair{span, closest, dist})
+		}
+	}
+	return
+}
+
+// For each span of a, the number of bases to the closest span of b on the
+// same chromosome; see ClosestSpans
+func NearestDistances(a, b Bed) (dists []int) {
+	for _, pair := range ClosestSpans(a, b) {
+		dists = append(dists, pair.Distance)
+	}
+	return
+}
+
+// The relative distance of each span of a between the spans of b; see
+// SpanIndex.RelDist. Spans without a span of b on each side are left out.
+func RelDists(a, b Bed) (reldists []float64) {
+	idx := NewSpanIndex(b)
+	for _, span := range AllBedSpans(a) {
+		if rd, ok := idx.RelDist(span); ok {
+			reldists = append(reldists, rd)
+		}
+	}
+	return
+}
+
+// Number of bins in RelDistHist
+const RelDistBins = 50
+
+// The number of relative distances in each of RelDistBins bins of equal width
+// from 0 to 0.5. Relative distances of unrelated beds are spread evenly
+// between the bins, and those of associated beds are piled up near 0.
+func RelDistHist(reldists []float64) []int {
+	hist := make([]int, RelDistBins)
+	for _, rd := range reldists {
+		i := int(rd * 2 * RelDistBins)
+		if i >= RelDistBins { i = RelDistBins - 1 }
+		if i < 0 { i = 0 }
+		hist[i]++
+	}
+	return hist
+}
+
+// NaN if x is empty
+func medianInts(x []int) float64 {
+	data := make(stats.Float64Data, 0, len(x))
+	for _, v := range x {
+		data = append(data, float64(v))
+	}
+	median, err := data.Median()
+	if err != nil {
+		return math.NaN()
+	}
+	return median
+}
+
+// NaN if x is empty
+func meanFloats(x []float64) float64 {
+	mean, err := stats.Mean(x)
+	if err != nil {
+		return math.NaN()
+	}
+	return mean
+}
+
+// The nearest-neighbour and relative distances from the spans of one bed to
+// another, and their null distributions
+type DistanceStat struct {
+	From string
+	To string
+	// The distance from each span of From to the closest span of To
+	Observed []int
+	Mean float64
+	Median float64
+	// The relative distance of each span of From between the spans of To
+	RelDist []float64
+	RelDistMean float64
+	// The mean and median distance, and mean relative distance, in each
+	// permutation
+	NullMean []float64
+	NullMedian []float64
+	NullRelDistMean []float64
+	// The fraction of permutations with a statistic at most the observed
+	// one, i.e. the probability of the beds being that close by chance
+	MeanProb float64
+	MedianProb float64
+	RelDistProb float64
+}
+
+type DistanceStats []DistanceStat
+
+func (d DistanceStat) Name() string {
+	return d.From + "->" + d.To
+}
+
+// Call f on every ordered pair of different beds, in the order of DistanceStats
+func bedPairs(beds Beds, f func(a, b Bed)) {
+	for _, a := range beds {
+		for _, b := range beds {
+			if a.Name != b.Name {
+				f(a, b)
+			}
+		}
+	}
+}
+
+// The distances between every ordered pair of beds, without a null
+// distribution
+func GetDistanceStats(beds Beds) (out DistanceStats) {
+	nan := math.NaN()
+	bedPairs(beds, func(a, b Bed) {
+		dists, rds := NearestDistances(a, b), RelDists(a, b)
+		out = append(out, DistanceStat {
+			From: a.Name,
+			To: b.Name,
+			Observed: dists,
+			Mean: meanInts(dists),
+			Median: medianInts(dists),
+			RelDist: rds,
+			RelDistMean: meanFloats(rds),
+			MeanProb: nan,
+			MedianProb: nan,
+			RelDistProb: nan,
+		})
+	})
+	return
+}
+
+// Add the statistics of permuted beds, which must be in the same order as the
+// beds of GetDistanceStats, to the null distributions
+func AddPermutationDistances(ds DistanceStats, permuted Beds) DistanceStats {
+	i := 0
+	bedPairs(permuted, func(a, b Bed) {
+		dists := NearestDistances(a, b)
+		ds[i].NullMean = append(ds[i].NullMean, meanInts(dists))
+		ds[i].NullMedian = append(ds[i].NullMedian, medianInts(dists))
+		ds[i].NullRelDistMean = append(ds[i].NullRelDistMean, meanFloats(RelDists(a, b)))
+		i++
+	})
+	return ds
+}
+
+// The fraction of null at most observed, leaving out NaNs; NaN if there are
+// none
+func probAtMost(observed float64, null []float64) float64 {
+	n, below := 0, 0
+	for _, x := range null {
+		if math.IsNaN(x) {
+			continue
+		}
+		n++
+		if x <= observed {
+			below++
+		}
+	}
+	if n < 1 || math.IsNaN(observed) {
+		return math.NaN()
+	}
+	return float64(below) / float64(n)
+}
+
+// Set the probabilities of ds from their null distributions
+func GetDistanceProbs(ds DistanceStats) {
+	for i := range ds {
+		ds[i].MeanProb = probAtMost(ds[i].Mean, ds[i].NullMean)
+		ds[i].MedianProb = probAtMost(ds[i].Median, ds[i].NullMedian)
+		ds[i].RelDistProb = probAtMost(ds[i].RelDistMean, ds[i].NullRelDistMean)
+	}
+}
+
+// Header of the table written by FprintDistances
+const DistancesHeader = "mean_prob\tmedian_prob\treldist_prob\tname\tn\tmean\tmedian\treldist_mean"
+
+// Write one line per pair of beds, in the column order of DistancesHeader
+func FprintDistances(w io.Writer, ds DistanceStats) {
+	for _, d := range ds {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", d.MeanProb, d.MedianProb, d.RelDistProb, d.Name(), len(d.Observed), d.Mean, d.Median, d.RelDistMean)
+	}
+}
+
+// Header of the table written by FprintRelDistHists
+const RelDistHeader = "name\tmin\tmax\tcount\tfraction"
+
+// Write the histogram of the relative distances of each pair of beds (see
+// RelDistHist), one line per bin, in the column order of RelDistHeader.
+// Unrelated beds have about 1 / RelDistBins of their spans in each bin.
+func FprintRelDistHists(w io.Writer, ds DistanceStats) {
+	for _, d := range ds {
+		for i, n := range RelDistHist(d.RelDist) {
+			width := 0.5 / RelDistBins
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", d.Name(), float64(i) * width, float64(i + 1) * width, n, float64(n) / float64(len(d.RelDist)))
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package permuvals
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestNearestDistance(t *testing.T) {
+	sorted := []Bspan{MakeBspan("one", 10, 20), MakeBspan("one", 30, 40)}
+	cases := []struct {
+		span Bspan
+		expected int
+	} {
+		{MakeBspan("one", 0, 5), 5},
+		{MakeBspan("one", 22, 25), 2},
+		{MakeBspan("one", 26, 29), 1},
+		{MakeBspan("one", 15, 35), 0},
+		{MakeBspan("one", 40, 45), 0},
+		{MakeBspan("one", 50, 60), 10},
+	}
+	idx := SpanIndex{"one": sorted}
+	for _, c := range cases {
+		if _, actual, ok := idx.Closest(c.span); !ok || actual != c.expected {
+			t.Errorf("%v: actual and expected do not match. Actual: %v. Expected: %v.", c.span, actual, c.expected)
+		}
+	}
+	if _, _, ok := idx.Closest(MakeBspan("two", 0, 5)); ok {
+		t.Errorf("found a nearest span on a chromosome without spans")
+	}
+}
+
+func TestClosestSpans(t *testing.T) {
+	a := toBed("a", []Bspan{MakeBspan("one", 0, 5), MakeBspan("one", 26, 29), MakeBspan("two", 0, 5)})
+	b := toBed("b", []Bspan{MakeBspan("one", 10, 20), MakeBspan("one", 30, 40)})
+	expected := []ClosestPair {
+		ClosestPair{MakeBspan("one", 0, 5), MakeBspan("one", 10, 20), 5},
+		ClosestPair{MakeBspan("one", 26, 29), MakeBspan("one", 30, 40), 1},
+	}
+	actual := ClosestSpans(a, b)
+	if len(actual) != len(expected) {
+		t.Fatalf("actual and expected do not match. Actual: %v. Expected: %v.", actual, expected)
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Errorf("actual and expected do not match. Actual: %v. Expected: %v.", actual[i], expected[i])
+		}
+	}
+}
+
+func TestRelDist(t *testing.T) {
+	// midpoints at 10, 30 and 70
+	idx := SpanIndex{"one": []Bspan{MakeBspan("one", 5, 15), MakeBspan("one", 25, 35), MakeBspan("one", 60, 80)}}
+	cases := []struct {
+		span Bspan
+		expected float64
+		ok bool
+	} {
+		{MakeBspan("one", 10, 12), 0.05, true},
+		{MakeBspan("one", 19, 21), 0.5, true},
+		{MakeBspan("one", 38, 42), 0.25, true},
+		{MakeBspan("one", 0, 2), 0, false},
+		{MakeBspan("one", 90, 100), 0, false},
+		{MakeBspan("two", 19, 21), 0, false},
+	}
+	for _, c := range cases {
+		if actual, ok := idx.RelDist(c.span); ok != c.ok || math.Abs(actual - c.expected) > 1e-9 {
+			t.Errorf("%v: actual and expected do not match. Actual: %v, %v. Expected: %v, %v.", c.span, actual, ok, c.expected, c.ok)
+		}
+	}
+
+	hist := RelDistHist([]float64{0, 0.005, 0.011, 0.25, 0.5})
+	if hist[0] != 2 || hist[1] != 1 || hist[25] != 1 || hist[RelDistBins-1] != 1 {
+		t.Errorf("unexpected reldist histogram %v", hist)
+	}
+}
+
+func TestNearestDistances(t *testing.T) {
+	a := toBed("a", []Bspan{MakeBspan("one", 0, 5), MakeBspan("one", 50, 60), MakeBspan("two", 0, 5), MakeBspan("three", 0, 5)})
+	b := toBed("b", []Bspan{MakeBspan("one", 10, 20), MakeBspan("one", 30, 40), MakeBspan("two", 100, 200)})
+	if actual, expected := NearestDistances(a, b), []int{5, 10, 95}; !sameInts(actual, expected) {
+		t.Errorf("actual and expected do not match. Actual: %v. Expected: %v.", actual, expected)
+	}
+
+	ds := GetDistanceStats(Beds{a, b})
+	if len(ds) != 2 || ds[0].Name() != "a->b" || ds[1].Name() != "b->a" {
+		t.Fatalf("unexpected distance stats %v", ds)
+	}
+	if ds[0].Mean != 110.0 / 3 || ds[0].Median != 10 {
+		t.Errorf("mean %v and median %v, expected %v and 10", ds[0].Mean, ds[0].Median, 110.0 / 3)
+	}
+	if !math.IsNaN(ds[0].MeanProb) {
+		t.Errorf("probability %v without permutations", ds[0].MeanProb)
+	}
+}
+
+func TestDistanceProbs(t *testing.T) {
+	// b is always 40 bp past a, much closer than chance
+	var aspans, bspans []Bspan
+	for i := 0; i < 10; i++ {
+		aspans = append(aspans, MakeBspan("one", i * 1000, i * 1000 + 10))
+		bspans = append(bspans, MakeBspan("one", i * 1000 + 50, i * 1000 + 60))
+	}
+	beds := Beds{toBed("a", aspans), toBed("b", bspans)}
+	genome := toBed("genome", []Bspan{MakeBspan("one", 0, 10000)})
+
+	ds := GetDistanceStats(beds)
+	randgen := rand.New(rand.NewSource(4))
+	for i := 0; i < 100; i++ {
+		ds = AddPermutationDistances(ds, PermuteBeds(beds, genome, randgen, []int{1}))
+	}
+	GetDistanceProbs(ds)
+	if ds[0].Mean != 40 || len(ds[0].NullMean) != 100 {
+		t.Fatalf("unexpected distances %v", ds[0])
+	}
+	if ds[0].MeanProb > 0.05 || ds[0].MedianProb > 0.05 || ds[0].RelDistProb > 0.05 {
+		t.Errorf("probabilities %v, %v and %v of close beds are not small", ds[0].MeanProb, ds[0].MedianProb, ds[0].RelDistProb)
+	}
+}
+
+func TestRelDistHists(t *testing.T) {
+	var aspans, bspans []Bspan
+	for i := 0; i < 10; i++ {
+		aspans = append(aspans, MakeBspan("one", i * 1000, i * 1000 + 10))
+		bspans = append(bspans, MakeBspan("one", i * 1000 + 50, i * 1000 + 60))
+	}
+	beds := Beds{toBed("a", aspans), toBed("b", bspans)}
+	genome := toBed("genome", []Bspan{MakeBspan("one", 0, 10000)})
+
+	ds := GetDistanceStats(beds)
+	randgen := rand.New(rand.NewSource(5))
+	for i := 0; i < 20; i++ {
+		ds = AddPermutationDistances(ds, PermuteBeds(beds, genome, randgen, nil))
+	}
+	GetDistanceProbs(ds)
+	if ds[0].RelDistMean > 0.05 || len(ds[0].NullRelDistMean) != 20 || ds[0].RelDistProb > 0.1 {
+		t.Errorf("unexpected relative distances %v", ds[0])
+	}
+
+	// a's 9 spans with a span of b on each side are all 0.05 of the way
+	// from the closer one
+	var b strings.Builder
+	FprintRelDistHists(&b, ds[:1])
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != RelDistBins || lines[5] != "a->b\t0.05\t0.06\t9\t1" || lines[0] != "a->b\t0\t0.01\t0\t0" {
+		t.Errorf("unexpected histogram lines %q", lines)
+	}
+}
@@ -0,0 +1,205 @@
+package permuvals
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jgbaldwinbrown/fasttsv"
+)
+
+// Formats that a genome (chromosome length) file can be read from
+const (
+	GenomeAuto = "auto"
+	// Three-column bed with one span per chromosome
+	GenomeBed = "bed"
+	// UCSC chrom.sizes: chromosome name and length
+	GenomeSizes = "sizes"
+	// samtools faidx index: name, length, offset, linebases, linewidth
+	GenomeFai = "fai"
+	// The reference sequence itself; see GenomeFromFasta
+	GenomeFasta = "fasta"
+)
+
+// Guess a genome format from the file extension, ignoring any .gz suffix.
+// Returns GenomeAuto if the extension is not informative.
+func GenomeFormatFromPath(path string) string {
+	path = strings.TrimSuffix(path, ".gz")
+	switch filepath.Ext(path) {
+	case ".fai":
+		return GenomeFai
+	case ".sizes", ".genome":
+		return GenomeSizes
+	case ".bed":
+		return GenomeBed
+	case ".fa", ".fasta", ".fna":
+		return GenomeFasta
+	}
+	return GenomeAuto
+}
+
+func allInts(fields []string) bool {
+	for _, f := range fields {
+		if _, err := strconv.Atoi(f); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Guess a genome format from the columns of its first line
+func GenomeFormatFromLine(line []string) string {
+	if len(line) == 2 {
+		return GenomeSizes
+	}
+	if (len(line) == 5 || len(line) == 6) && allInts(line[1:5]) {
+		return GenomeFai
+	}
+	return GenomeBed
+}
+
+// Parse one line of a chrom.sizes or .fai file into a span covering the whole chromosome
+func ParseSizesEntry(line []string) (s Bspan, e error) {
+	if len(line) < 2 { return s, fmt.Errorf("line too short") }
+	s.Chrom = line[0]
+	s.Max, e = strconv.Atoi(line[1])
+	return
+}
+
+func firstLine(data []byte) []string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" && line[0] != '#' {
+			return strings.Split(line, "\t")
+		}
+	}
+	return nil
+}
+
+// Read a genome in the given format. GenomeAuto detects the format from the
+// first line. minGap is only used for GenomeFasta; see GenomeFromFasta.
+func ParseGenome(r io.Reader, format string, minGap int) (b Bed, err error) {
+	br := bufio.NewReaderSize(r, 1 << 16)
+	if format == GenomeAuto {
+		// Peek fails with a short read for small files, which is fine
+		start, _ := br.Peek(1 << 16)
+		if len(start) > 0 && start[0] == '>' {
+			format = GenomeFasta
+		} else {
+			format = GenomeFormatFromLine(firstLine(start))
+		}
+	}
+
+	var parse func([]string) (Bspan, error)
+	switch format {
+	case GenomeFasta:
+		return GenomeFromFasta(br, minGap)
+	case GenomeBed:
+		parse = ParseBedEntry
+	case GenomeSizes, GenomeFai:
+		parse = ParseSizesEntry
+	default:
+		return b, fmt.Errorf("unknown genome format %q", format)
+	}
+
+	b = MakeBed("genome")
+	s := fasttsv.NewScanner(br)
+	for s.Scan() {
+		line := s.Line()
+		if len(line) == 0 || strings.HasPrefix(line[0], "#") {
+			continue
+		}
+		var bspan Bspan
+		bspan, err = parse(line)
+		if err != nil { return }
+		b.AddBspans(bspan)
+	}
+	return
+}
+
+// Read the genome at path. If format is GenomeAuto, the format is taken from
+// the file extension, or else from the file contents.
+func GetGenomeFormat(path string, format string, minGap int) (b Bed, err error) {
+	if format == GenomeAuto {
+		format = GenomeFormatFromPath(path)
+	}
+	r, err := OpenPath(path)
+	if err != nil { return }
+	defer r.Close()
+	return ParseGenome(r, format, minGap)
+}
+
+// Build a genome from a FASTA file, with one span per chromosome. If minGap
+// is positive, runs of more than minGap N bases are left out of the genome,
+// splitting the chromosome into several spans, so that permuted spans are
+// never placed in assembly gaps.
+func GenomeFromFasta(r io.Reader, minGap int) (b Bed, err error) {
+	b = MakeBed("genome")
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 1 << 16), 1 << 30)
+
+	chrom := ""
+	// pos is the current position, start is the start of the current
+	// non-gap span, and nrun is the length of the run of Ns ending at pos
+	pos, start, nrun := 0, 0, 0
+	isGap := func() bool { return minGap > 0 && nrun > minGap }
+	finish := func() {
+		end := pos
+		if isGap() {
+			end = pos - nrun
+		}
+		if chrom != "" && end > start {
+			b.AddBspans(MakeBspan(chrom, start, end))
+		}
+	}
+
+	for s.Scan() {
+		line := bytes.TrimRight(s.Bytes(), "\r")
+		if len(line) > 0 && line[0] == '>' {
+			finish()
+			fields := strings.Fields(string(line[1:]))
+			if len(fields) < 1 {
+				return b, fmt.Errorf("FASTA header with no name")
+			}
+			chrom = fields[0]
+			pos, start, nrun = 0, 0, 0
+			continue
+		}
+		if len(line) > 0 && chrom == "" {
+			return b, fmt.Errorf("FASTA sequence before first header")
+		}
+		for _, c := range line {
+			if c == 'N' || c == 'n' {
+				nrun++
+			} else {
+				if isGap() {
+					if gapstart := pos - nrun; gapstart > start {
+						b.AddBspans(MakeBspan(chrom, start, gapstart))
+					}
+					start = pos
+				}
+				nrun = 0
+			}
+			pos++
+		}
+	}
+	if err = s.Err(); err != nil { return }
+	finish()
+	return
+}
+
+// Copy b, leaving out any chromosome whose name matches exclude
+func FilterChroms(b Bed, exclude *regexp.Regexp) Bed {
+	out := MakeBed(b.Name)
+	for _, chrom := range b.Chroms {
+		if exclude.MatchString(chrom) {
+			continue
+		}
+		out.AddBspans(AllBspans(chrom, b.Intervals[chrom])...)
+	}
+	return out
+}
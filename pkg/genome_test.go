@@ -0,0 +1,101 @@
+package permuvals
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func checkGenome(t *testing.T, genome Bed, expected []Bspan) {
+	spans := AllBedSpans(genome)
+	if len(spans) != len(expected) {
+		t.Fatalf("actual and expected do not match. Actual: %v. Expected: %v.", spans, expected)
+	}
+	for i, b := range spans {
+		if b != expected[i] {
+			t.Errorf("actual and expected do not match. Actual: %v. Expected: %v.", spans, expected)
+		}
+	}
+}
+
+func TestParseGenomeFormats(t *testing.T) {
+	inputs := map[string]string {
+		GenomeBed: "one\t0\t200\ntwo\t0\t300\n",
+		GenomeSizes: "one\t200\ntwo\t300\n",
+		GenomeFai: "one\t200\t5\t60\t61\ntwo\t300\t215\t60\t61\n",
+	}
+	for format, in := range inputs {
+		for _, f := range []string{format, GenomeAuto} {
+			genome, err := ParseGenome(strings.NewReader(in), f, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGenome(t, genome, genomeBspans())
+		}
+	}
+}
+
+func TestGenomeFormatFromPath(t *testing.T) {
+	cases := map[string]string {
+		"hg38.fa.fai": GenomeFai,
+		"hg38.chrom.sizes.gz": GenomeSizes,
+		"g.bed": GenomeBed,
+		"g.txt": GenomeAuto,
+	}
+	for path, expected := range cases {
+		if f := GenomeFormatFromPath(path); f != expected {
+			t.Errorf("format of %v: %v != %v", path, f, expected)
+		}
+	}
+}
+
+func TestFilterChroms(t *testing.T) {
+	in := "one\t200\nchrUn_x\t50\ntwo\t300\none_random\t10\n"
+	genome, err := ParseGenome(strings.NewReader(in), GenomeSizes, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genome = FilterChroms(genome, regexp.MustCompile(`_random$|^chrUn`))
+	checkGenome(t, genome, genomeBspans())
+}
+
+func TestGenomeFromFasta(t *testing.T) {
+	in := ">one description\nACGTNNNNAC\nNNGT\n>two\nNNAC\nGTNN\n"
+	expected := map[int][]Bspan {
+		0: []Bspan{MakeBspan("one", 0, 14), MakeBspan("two", 0, 8)},
+		// runs exactly minGap long are kept
+		4: []Bspan{MakeBspan("one", 0, 14), MakeBspan("two", 0, 8)},
+		3: []Bspan{MakeBspan("one", 0, 4), MakeBspan("one", 8, 14), MakeBspan("two", 0, 8)},
+		2: []Bspan{MakeBspan("one", 0, 4), MakeBspan("one", 8, 14), MakeBspan("two", 0, 8)},
+		1: []Bspan{MakeBspan("one", 0, 4), MakeBspan("one", 8, 10), MakeBspan("one", 12, 14), MakeBspan("two", 2, 6)},
+	}
+	for minGap, exp := range expected {
+		genome, err := ParseGenome(strings.NewReader(in), GenomeAuto, minGap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkGenome(t, genome, exp)
+	}
+}
+
+func TestRaw2BspanGaps(t *testing.T) {
+	genome := toBed("genome", []Bspan{MakeBspan("one", 0, 4), MakeBspan("one", 8, 14)})
+	span := MakeBspan("x", 0, 3)
+	npos := SpanNumPositions(span, genome)
+	if npos != 6 {
+		t.Fatalf("npos (%v) not equal to 6", npos)
+	}
+	expected := []Bspan {
+		MakeBspan("one", 0, 3),
+		MakeBspan("one", 1, 4),
+		MakeBspan("one", 8, 11),
+		MakeBspan("one", 9, 12),
+		MakeBspan("one", 10, 13),
+		MakeBspan("one", 11, 14),
+	}
+	for i := 0; i < npos; i++ {
+		if b := Raw2Bspan(i, span, genome); b != expected[i] {
+			t.Errorf("Raw2Bspan(%v) %v != %v", i, b, expected[i])
+		}
+	}
+}
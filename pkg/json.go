@@ -0,0 +1,573 @@
+package permuvals
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/montanaflynn/stats"
+)
+
+// Output formats for a Comparison
+const (
+	OutTSV = "tsv"
+	OutJSON = "json"
+	OutJSONL = "jsonl"
+)
+
+// Identifies the JSON layout of a Comparison; bumped on incompatible changes.
+//
+// The JSON encoding of a Comparison is a single object:
+//
+//	{
+//	  "schema": "permuvals.comparison/1",
+//	  "meta": {
+//	    "genome": path, "genome_format": string, "min_gap": int,
+//	    "chrom_exclude": string,
+//	    "iterations": int, "seed": int, "max_comps": int, "subsets": [[label]],
+//	    "min_overlap": {"bp": int, "frac_a", "frac_b": float, "reciprocal": bool},
+//	    "window": int, "window_beds": [int], "exclusive": bool,
+//	    "distance": bool, "pairwise": bool, "to_permute": [int],
+//	    "input_hash": string, "merged_seeds": [int],
+//	    "inputs": [{"path", "label", "group", "permute": bool or null, "options": {}}]
+//	  },
+//	  "subsets": [{
+//	    "name": "a:b", "components": ["a", "b"],
+//	    "observed": {"count": int, "covered": int, "spans": [[chrom, start, end]]},
+//	    "null": {
+//	      "count": [int], "covered": [int],
+//	      "count_summary": {"mean", "sd", "min", "median", "max"},
+//	      "covered_summary": {"mean", "sd", "min", "median", "max"}
+//	    },
+//	    "prob": {"count": float, "covered": float}
+//	  }],
+//	  "distances": [{
+//	    "from": "a", "to": "b",
+//	    "observed": {
+//	      "distances": [int], "mean": float, "median": float,
+//	      "reldist": [float], "reldist_mean": float
+//	    },
+//	    "null": {"mean": [float], "median": [float], "reldist_mean": [float]},
+//	    "prob": {"mean": float, "median": float, "reldist_mean": float}
+//	  }],
+//	  "pairs": [{
+//	    "query": "a", "reference": "b",
+//	    "observed": {"count": int, "covered": int},
+//	    "null": {"count": [int], "covered": [int]},
+//	    "prob": {"count": float, "covered": float}
+//	  }]
+//	}
+//
+// "merged_seeds" is only present in merged shards, "subsets" only if the
+// subsets were chosen, "distances" only with "distance" set, and "pairs" only
+// with "pairwise" set. "null" and "prob" are left out if no permutations were
+// run. Numbers that are not finite (e.g. the mean of no values) are written as
+// null. The JSON Lines encoding has the same content, with a first line
+// holding {"schema", "type": "meta", "meta"} followed by one line per subset
+// holding {"type": "subset", ...subset fields}, one per distance holding
+// {"type": "distance", ...distance fields}, and one per pair holding
+// {"type": "pair", ...pair fields}.
+//
+// The permuted beds themselves (Comparison.Permutations) are not encoded, so
+// they are empty after decoding.
+const ComparisonSchema = "permuvals.comparison/1"
+
+type jsonComparison struct {
+	Schema string `json:"schema"`
+	Type string `json:"type,omitempty"`
+	Meta *jsonMeta `json:"meta,omitempty"`
+	Subsets []jsonSubset `json:"subsets,omitempty"`
+	Distances []jsonDistance `json:"distances,omitempty"`
+	Pairs []jsonPair `json:"pairs,omitempty"`
+}
+
+type jsonMeta struct {
+	Genome string `json:"genome"`
+	GenomeFormat string `json:"genome_format"`
+	MinGap int `json:"min_gap"`
+	ChromExclude string `json:"chrom_exclude"`
+	Iterations int `json:"iterations"`
+	Seed int `json:"seed"`
+	MaxComps int `json:"max_comps"`
+	Subsets [][]string `json:"subsets,omitempty"`
+	MinOverlap jsonMinOverlap `json:"min_overlap"`
+	Window int `json:"window"`
+	WindowBeds []int `json:"window_beds"`
+	Exclusive bool `json:"exclusive"`
+	Distance bool `json:"distance"`
+	Pairwise bool `json:"pairwise"`
+	ToPermute []int `json:"to_permute"`
+	InputHash string `json:"input_hash"`
+	MergedSeeds []int `json:"merged_seeds,omitempty"`
+	Inputs []jsonInput `json:"inputs"`
+}
+
+type jsonMinOverlap struct {
+	Bp int `json:"bp"`
+	FracA float64 `json:"frac_a"`
+	FracB float64 `json:"frac_b"`
+	Reciprocal bool `json:"reciprocal"`
+}
+
+type jsonInput struct {
+	Path string `json:"path"`
+	Label string `json:"label"`
+	Group string `json:"group"`
+	Permute *bool `json:"permute"`
+	Options map[string]string `json:"options"`
+}
+
+type jsonSubset struct {
+	Type string `json:"type,omitempty"`
+	Name string `json:"name"`
+	Components []string `json:"components"`
+	Observed jsonObserved `json:"observed"`
+	Null *jsonNull `json:"null,omitempty"`
+	Prob *jsonProb `json:"prob,omitempty"`
+}
+
+type jsonObserved struct {
+	Count int `json:"count"`
+	Covered int `json:"covered"`
+	Spans []jsonSpan `json:"spans"`
+}
+
+// A span, encoded as [chrom, start, end]
+type jsonSpan Bspan
+
+type jsonNull struct {
+	Count []int `json:"count"`
+	Covered []int `json:"covered"`
+	CountSummary jsonSummary `json:"count_summary"`
+	CoveredSummary jsonSummary `json:"covered_summary"`
+}
+
+type jsonSummary struct {
+	Mean jsonFloat `json:"mean"`
+	SD jsonFloat `json:"sd"`
+	Min jsonFloat `json:"min"`
+	Median jsonFloat `json:"median"`
+	Max jsonFloat `json:"max"`
+}
+
+type jsonDistance struct {
+	Type string `json:"type,omitempty"`
+	From string `json:"from"`
+	To string `json:"to"`
+	Observed jsonDistanceObserved `json:"observed"`
+	Null *jsonDistanceNull `json:"null,omitempty"`
+	Prob *jsonDistanceProb `json:"prob,omitempty"`
+}
+
+type jsonDistanceObserved struct {
+	Distances []int `json:"distances"`
+	Mean jsonFloat `json:"mean"`
+	Median jsonFloat `json:"median"`
+	RelDist []jsonFloat `json:"reldist"`
+	RelDistMean jsonFloat `json:"reldist_mean"`
+}
+
+type jsonDistanceNull struct {
+	Mean []jsonFloat `json:"mean"`
+	Median []jsonFloat `json:"median"`
+	RelDistMean []jsonFloat `json:"reldist_mean"`
+}
+
+type jsonDistanceProb struct {
+	Mean jsonFloat `json:"mean"`
+	Median jsonFloat `json:"median"`
+	RelDistMean jsonFloat `json:"reldist_mean"`
+}
+
+type jsonPair struct {
+	Type string `json:"type,omitempty"`
+	Query string `json:"query"`
+	Reference string `json:"reference"`
+	Observed jsonPairObserved `json:"observed"`
+	Null *jsonPairNull `json:"null,omitempty"`
+	Prob *jsonProb `json:"prob,omitempty"`
+}
+
+type jsonPairObserved struct {
+	Count int `json:"count"`
+	Covered int `json:"covered"`
+}
+
+type jsonPairNull struct {
+	Count []int `json:"count"`
+	Covered []int `json:"covered"`
+}
+
+type jsonProb struct {
+	Count jsonFloat `json:"count"`
+	Covered jsonFloat `json:"covered"`
+}
+
+// A float64 that is encoded as null when it is NaN or infinite
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(f))
+}
+
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*f = jsonFloat(math.NaN())
+		return nil
+	}
+	return json.Unmarshal(data, (*float64)(f))
+}
+
+func (s jsonSpan) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{s.Chrom, s.Min, s.Max})
+}
+
+func (s *jsonSpan) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if len(fields) != 3 {
+		return fmt.Errorf("span %s does not have 3 fields", data)
+	}
+	if err := json.Unmarshal(fields[0], &s.Chrom); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(fields[1], &s.Min); err != nil {
+		return err
+	}
+	return json.Unmarshal(fields[2], &s.Max)
+}
+
+// Summarize a null distribution
+func summarize(vals []int) jsonSummary {
+	data := make(stats.Float64Data, 0, len(vals))
+	for _, v := range vals {
+		data = append(data, float64(v))
+	}
+	get := func(f func() (float64, error)) jsonFloat {
+		x, err := f()
+		if err != nil {
+			return jsonFloat(math.NaN())
+		}
+		return jsonFloat(x)
+	}
+	return jsonSummary {
+		Mean: get(data.Mean),
+		SD: get(data.StandardDeviationSample),
+		Min: get(data.Min),
+		Median: get(data.Median),
+		Max: get(data.Max),
+	}
+}
+
+func encodeMeta(c Comparison) *jsonMeta {
+	m := &jsonMeta {
+		Genome: c.Meta.GenomePath,
+		GenomeFormat: c.Meta.GenomeFormat,
+		MinGap: c.Meta.MinGap,
+		ChromExclude: c.Meta.ChromExclude,
+		Iterations: c.Meta.Iterations,
+		Seed: c.Meta.Rseed,
+		MaxComps: c.Meta.MaxComps,
+		Subsets: c.Meta.Subsets,
+		MinOverlap: jsonMinOverlap(c.Meta.Min),
+		Window: c.Meta.Window,
+		WindowBeds: c.Meta.WindowBeds,
+		Exclusive: c.Meta.Exclusive,
+		Distance: c.Meta.Distance,
+		Pairwise: c.Meta.Pairwise,
+		ToPermute: c.Meta.ToPermute,
+		InputHash: c.Meta.InputHash,
+		MergedSeeds: c.Meta.MergedSeeds,
+		Inputs: []jsonInput{},
+	}
+	if m.ToPermute == nil {
+		m.ToPermute = []int{}
+	}
+	if m.WindowBeds == nil {
+		m.WindowBeds = []int{}
+	}
+	for _, e := range c.Inputs {
+		in := jsonInput{Path: e.Path, Label: e.Label, Group: e.Group, Options: e.Options}
+		if e.PermuteSet {
+			permute := e.Permute
+			in.Permute = &permute
+		}
+		if in.Options == nil {
+			in.Options = map[string]string{}
+		}
+		m.Inputs = append(m.Inputs, in)
+	}
+	return m
+}
+
+func encodeSubsets(c Comparison) []jsonSubset {
+	counts := make(map[string]OverlapCount, len(c.IterCounts))
+	for _, count := range c.IterCounts {
+		counts[count.Name] = count
+	}
+	probs := make(map[string]Prob, len(c.Probs))
+	for _, prob := range c.Probs {
+		probs[prob.Name] = prob
+	}
+
+	subsets := make([]jsonSubset, 0, len(c.Overlaps))
+	for _, ovl := range c.Overlaps {
+		bspans := AllBedSpans(ovl.Bed)
+		s := jsonSubset {
+			Name: ovl.Name,
+			Components: ovl.Components,
+			Observed: jsonObserved{Count: len(bspans), Covered: Covered(bspans), Spans: []jsonSpan{}},
+		}
+		if s.Components == nil {
+			s.Components = []string{}
+		}
+		for _, b := range bspans {
+			s.Observed.Spans = append(s.Observed.Spans, jsonSpan(b))
+		}
+		if count, ok := counts[ovl.Name]; ok {
+			s.Null = &jsonNull {
+				Count: count.Count,
+				Covered: count.Covered,
+				CountSummary: summarize(count.Count),
+				CoveredSummary: summarize(count.Covered),
+			}
+		}
+		if prob, ok := probs[ovl.Name]; ok {
+			s.Prob = &jsonProb{jsonFloat(prob.CountProb), jsonFloat(prob.CoveredProb)}
+		}
+		subsets = append(subsets, s)
+	}
+	return subsets
+}
+
+func jsonFloats(x []float64) []jsonFloat {
+	out := make([]jsonFloat, 0, len(x))
+	for _, v := range x {
+		out = append(out, jsonFloat(v))
+	}
+	return out
+}
+
+func encodeDistances(c Comparison) []jsonDistance {
+	out := make([]jsonDistance, 0, len(c.Distances))
+	for _, d := range c.Distances {
+		jd := jsonDistance {
+			From: d.From,
+			To: d.To,
+			Observed: jsonDistanceObserved {
+				Distances: d.Observed,
+				Mean: jsonFloat(d.Mean),
+				Median: jsonFloat(d.Median),
+				RelDist: jsonFloats(d.RelDist),
+				RelDistMean: jsonFloat(d.RelDistMean),
+			},
+		}
+		if jd.Observed.Distances == nil {
+			jd.Observed.Distances = []int{}
+		}
+		if c.Meta.Iterations > 0 {
+			jd.Null = &jsonDistanceNull{jsonFloats(d.NullMean), jsonFloats(d.NullMedian), jsonFloats(d.NullRelDistMean)}
+			jd.Prob = &jsonDistanceProb{jsonFloat(d.MeanProb), jsonFloat(d.MedianProb), jsonFloat(d.RelDistProb)}
+		}
+		out = append(out, jd)
+	}
+	return out
+}
+
+func encodePairs(c Comparison) []jsonPair {
+	out := make([]jsonPair, 0, len(c.Pairs))
+	for _, p := range c.Pairs {
+		jp := jsonPair{Query: p.Query, Reference: p.Reference, Observed: jsonPairObserved{p.Count, p.Covered}}
+		if c.Meta.Iterations > 0 {
+			jp.Null = &jsonPairNull{p.NullCount, p.NullCovered}
+			jp.Prob = &jsonProb{jsonFloat(p.CountProb), jsonFloat(p.CoveredProb)}
+		}
+		out = append(out, jp)
+	}
+	return out
+}
+
+// Write c as a single JSON object; see ComparisonSchema for the layout
+func FprintComparisonJSON(w io.Writer, c Comparison) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(jsonComparison{Schema: ComparisonSchema, Meta: encodeMeta(c), Subsets: encodeSubsets(c), Distances: encodeDistances(c), Pairs: encodePairs(c)})
+}
+
+// Write c as JSON Lines, with the metadata first and then one subset per line
+func FprintComparisonJSONL(w io.Writer, c Comparison) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(jsonComparison{Schema: ComparisonSchema, Type: "meta", Meta: encodeMeta(c)}); err != nil {
+		return err
+	}
+	for _, s := range encodeSubsets(c) {
+		s.Type = "subset"
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	for _, d := range encodeDistances(c) {
+		d.Type = "distance"
+		if err := enc.Encode(d); err != nil {
+			return err
+		}
+	}
+	for _, p := range encodePairs(c) {
+		p.Type = "pair"
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeMeta(m *jsonMeta, c *Comparison) {
+	if m == nil {
+		return
+	}
+	c.Meta = RunMeta {
+		GenomePath: m.Genome,
+		GenomeFormat: m.GenomeFormat,
+		MinGap: m.MinGap,
+		ChromExclude: m.ChromExclude,
+		Iterations: m.Iterations,
+		Rseed: m.Seed,
+		OverlapOpts: OverlapOpts{MaxComps: m.MaxComps, Subsets: m.Subsets, Min: MinOverlap(m.MinOverlap), Window: m.Window, WindowBeds: m.WindowBeds, Exclusive: m.Exclusive},
+		Distance: m.Distance,
+		Pairwise: m.Pairwise,
+		ToPermute: m.ToPermute,
+		InputHash: m.InputHash,
+		MergedSeeds: m.MergedSeeds,
+	}
+	for _, in := range m.Inputs {
+		e := ManifestEntry{Path: in.Path, Label: in.Label, Group: in.Group, Options: in.Options}
+		if in.Permute != nil {
+			e.PermuteSet = true
+			e.Permute = *in.Permute
+		}
+		c.Inputs = append(c.Inputs, e)
+	}
+}
+
+func decodeSubset(s jsonSubset, c *Comparison) {
+	ovl := Overlap{MakeBed(s.Name), s.Components}
+	spans := make([]Bspan, 0, len(s.Observed.Spans))
+	for _, span := range s.Observed.Spans {
+		spans = append(spans, Bspan(span))
+	}
+	ovl.AddBspans(spans...)
+	c.Overlaps = append(c.Overlaps, ovl)
+
+	if s.Null != nil {
+		c.IterCounts = append(c.IterCounts, OverlapCount {
+			Count: s.Null.Count,
+			Covered: s.Null.Covered,
+			Name: s.Name,
+			Components: s.Components,
+		})
+	}
+	if s.Prob != nil {
+		c.Probs = append(c.Probs, Prob{s.Name, float64(s.Prob.Count), float64(s.Prob.Covered)})
+	}
+}
+
+func floats(x []jsonFloat) []float64 {
+	var out []float64
+	for _, v := range x {
+		out = append(out, float64(v))
+	}
+	return out
+}
+
+func decodeDistance(d jsonDistance, c *Comparison) {
+	nan := math.NaN()
+	out := DistanceStat {
+		From: d.From,
+		To: d.To,
+		Observed: d.Observed.Distances,
+		Mean: float64(d.Observed.Mean),
+		Median: float64(d.Observed.Median),
+		RelDist: floats(d.Observed.RelDist),
+		RelDistMean: float64(d.Observed.RelDistMean),
+		MeanProb: nan,
+		MedianProb: nan,
+		RelDistProb: nan,
+	}
+	if d.Null != nil {
+		out.NullMean, out.NullMedian, out.NullRelDistMean = floats(d.Null.Mean), floats(d.Null.Median), floats(d.Null.RelDistMean)
+	}
+	if d.Prob != nil {
+		out.MeanProb, out.MedianProb, out.RelDistProb = float64(d.Prob.Mean), float64(d.Prob.Median), float64(d.Prob.RelDistMean)
+	}
+	c.Distances = append(c.Distances, out)
+}
+
+func decodePair(p jsonPair, c *Comparison) {
+	nan := math.NaN()
+	out := PairStat{Query: p.Query, Reference: p.Reference, Count: p.Observed.Count, Covered: p.Observed.Covered, CountProb: nan, CoveredProb: nan}
+	if p.Null != nil {
+		out.NullCount, out.NullCovered = p.Null.Count, p.Null.Covered
+	}
+	if p.Prob != nil {
+		out.CountProb, out.CoveredProb = float64(p.Prob.Count), float64(p.Prob.Covered)
+	}
+	c.Pairs = append(c.Pairs, out)
+}
+
+// Read a Comparison written by FprintComparisonJSON or FprintComparisonJSONL
+func ReadComparisonJSON(r io.Reader) (c Comparison, err error) {
+	dec := json.NewDecoder(r)
+	var first jsonComparison
+	if err = dec.Decode(&first); err != nil { return }
+	if first.Schema != ComparisonSchema {
+		return c, fmt.Errorf("unsupported comparison schema %q", first.Schema)
+	}
+	decodeMeta(first.Meta, &c)
+	for _, s := range first.Subsets {
+		decodeSubset(s, &c)
+	}
+	for _, d := range first.Distances {
+		decodeDistance(d, &c)
+	}
+	for _, p := range first.Pairs {
+		decodePair(p, &c)
+	}
+	if first.Type != "meta" {
+		return
+	}
+
+	for {
+		var line json.RawMessage
+		err = dec.Decode(&line)
+		if err == io.EOF {
+			return c, nil
+		}
+		if err != nil { return }
+		var typ struct {
+			Type string `json:"type"`
+		}
+		if err = json.Unmarshal(line, &typ); err != nil { return }
+		switch typ.Type {
+		case "distance":
+			var d jsonDistance
+			if err = json.Unmarshal(line, &d); err != nil { return }
+			decodeDistance(d, &c)
+		case "pair":
+			var p jsonPair
+			if err = json.Unmarshal(line, &p); err != nil { return }
+			decodePair(p, &c)
+		case "subset":
+			var s jsonSubset
+			if err = json.Unmarshal(line, &s); err != nil { return }
+			decodeSubset(s, &c)
+		default:
+			return c, fmt.Errorf("unknown JSON Lines record type %q", typ.Type)
+		}
+	}
+}
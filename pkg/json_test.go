@@ -0,0 +1,140 @@
+package permuvals
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func testComparison() Comparison {
+	in1b := toBed("first", in1Bspans())
+	in2b := toBed("second", in2Bspans())
+	c := Comparison {
+		Overlaps: GetOverlaps(Beds{in1b, in2b}, -1),
+		Inputs: Manifest {
+			ManifestEntry{Path: "a.bed", Label: "first", PermuteSet: true, Permute: true},
+			ManifestEntry{Path: "b.bed", Label: "second", Group: "chip", Options: map[string]string{"minlen": "10"}},
+		},
+		Meta: RunMeta{GenomePath: "g.bed", GenomeFormat: GenomeAuto, MinGap: 10, ChromExclude: "_random$", Iterations: 2, Rseed: 3, OverlapOpts: OverlapOpts{MaxComps: -1, Subsets: [][]string{{"first", "second"}}, Min: MinOverlap{Bp: 1, FracB: 0.5}}},
+	}
+	b1 := toBed("x", []Bspan{MakeBspan("one", 3, 5)})
+	b2 := toBed("x", []Bspan{MakeBspan("one", 3, 5), MakeBspan("two", 0, 100)})
+	c.IterCounts = CountPermutations(OverlapSets {
+		GetOverlaps(Beds{b1, b2}, -1),
+		GetOverlaps(Beds{b2, b2}, -1),
+	})
+	for i := range c.IterCounts {
+		c.IterCounts[i].Name = c.Overlaps[i].Name
+		c.IterCounts[i].Components = c.Overlaps[i].Components
+	}
+	c.Probs = GetProbs(c.Overlaps, c.IterCounts)
+	c.Meta.Distance = true
+	c.Distances = GetDistanceStats(Beds{in1b, in2b})
+	for i := 0; i < 2; i++ {
+		c.Distances = AddPermutationDistances(c.Distances, Beds{b1, toBed("second", in2Bspans())})
+	}
+	GetDistanceProbs(c.Distances)
+	c.Pairs = PairPermutations(Beds{in1b, in2b}, toBed("genome", genomeBspans()), 2, rand.New(rand.NewSource(1)), nil, c.Meta.OverlapOpts)
+	return c
+}
+
+func checkComparisonsEqual(t *testing.T, actual, expected Comparison) {
+	if len(actual.Overlaps) != len(expected.Overlaps) {
+		t.Fatalf("decoded %v overlaps, expected %v", len(actual.Overlaps), len(expected.Overlaps))
+	}
+	for i, ovl := range actual.Overlaps {
+		a, e := AllBedSpans(ovl.Bed), AllBedSpans(expected.Overlaps[i].Bed)
+		if ovl.Name != expected.Overlaps[i].Name || len(a) != len(e) || len(ovl.Components) != len(expected.Overlaps[i].Components) {
+			t.Errorf("overlap %v does not match %v", ovl, expected.Overlaps[i])
+			continue
+		}
+		for j := range a {
+			if a[j] != e[j] {
+				t.Errorf("overlap spans %v do not match %v", a, e)
+			}
+		}
+	}
+	for i, count := range actual.IterCounts {
+		x := expected.IterCounts[i]
+		if count.Name != x.Name || len(count.Count) != len(x.Count) || count.Count[1] != x.Count[1] || count.Covered[1] != x.Covered[1] {
+			t.Errorf("counts %v do not match %v", count, x)
+		}
+	}
+	for i, prob := range actual.Probs {
+		x := expected.Probs[i]
+		same := func(a, b float64) bool { return a == b || (math.IsNaN(a) && math.IsNaN(b)) }
+		if prob.Name != x.Name || !same(prob.CountProb, x.CountProb) || !same(prob.CoveredProb, x.CoveredProb) {
+			t.Errorf("prob %v does not match %v", prob, x)
+		}
+	}
+	if len(actual.Distances) != len(expected.Distances) {
+		t.Fatalf("decoded %v distances, expected %v", len(actual.Distances), len(expected.Distances))
+	}
+	for i, d := range actual.Distances {
+		x := expected.Distances[i]
+		if d.Name() != x.Name() || !sameInts(d.Observed, x.Observed) || d.Mean != x.Mean || len(d.NullMedian) != len(x.NullMedian) || d.NullMedian[1] != x.NullMedian[1] || d.MeanProb != x.MeanProb || len(d.RelDist) != len(x.RelDist) || len(d.NullRelDistMean) != len(x.NullRelDistMean) {
+			t.Errorf("distance %v does not match %v", d, x)
+		}
+	}
+	if len(actual.Pairs) != len(expected.Pairs) {
+		t.Fatalf("decoded %v pairs, expected %v", len(actual.Pairs), len(expected.Pairs))
+	}
+	for i, p := range actual.Pairs {
+		x := expected.Pairs[i]
+		if p.Name() != x.Name() || p.Count != x.Count || p.Covered != x.Covered || !sameInts(p.NullCovered, x.NullCovered) || p.CoveredProb != x.CoveredProb {
+			t.Errorf("pair %v does not match %v", p, x)
+		}
+	}
+	if actual.Meta.Rseed != expected.Meta.Rseed || actual.Meta.GenomePath != expected.Meta.GenomePath || actual.Meta.MinGap != expected.Meta.MinGap || actual.Meta.ChromExclude != expected.Meta.ChromExclude || actual.Meta.Min != expected.Meta.Min || !sameSubsets(actual.Meta.Subsets, expected.Meta.Subsets) {
+		t.Errorf("meta %v does not match %v", actual.Meta, expected.Meta)
+	}
+	if len(actual.Inputs) != 2 || !actual.Inputs[0].PermuteSet || actual.Inputs[1].PermuteSet || actual.Inputs[1].Group != "chip" || actual.Inputs[1].Options["minlen"] != "10" {
+		t.Errorf("inputs %v do not match %v", actual.Inputs, expected.Inputs)
+	}
+}
+
+func TestComparisonJSONRoundTrip(t *testing.T) {
+	c := testComparison()
+	for _, format := range []string{OutJSON, OutJSONL} {
+		var buf bytes.Buffer
+		var err error
+		if format == OutJSON {
+			err = FprintComparisonJSON(&buf, c)
+		} else {
+			err = FprintComparisonJSONL(&buf, c)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := 1 + len(c.Overlaps) + len(c.Distances) + len(c.Pairs); format == OutJSONL && strings.Count(buf.String(), "\n") != n {
+			t.Errorf("expected %v JSON lines, got:\n%v", n, buf.String())
+		}
+		decoded, err := ReadComparisonJSON(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkComparisonsEqual(t, decoded, c)
+	}
+}
+
+func TestReadComparisonJSONSchema(t *testing.T) {
+	if _, err := ReadComparisonJSON(strings.NewReader(`{"schema": "other"}`)); err == nil {
+		t.Errorf("unknown schema should be an error")
+	}
+}
+
+func TestReadComparisonJSONLType(t *testing.T) {
+	var buf bytes.Buffer
+	if err := FprintComparisonJSONL(&buf, testComparison()); err != nil {
+		t.Fatal(err)
+	}
+	bad := strings.Replace(buf.String(), `"type":"subset"`, `"type":"sbuset"`, 1)
+	if bad == buf.String() {
+		t.Fatalf("no subset line in:\n%v", buf.String())
+	}
+	if _, err := ReadComparisonJSON(strings.NewReader(bad)); err == nil {
+		t.Errorf("unknown line type should be an error")
+	}
+}
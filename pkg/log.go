@@ -0,0 +1,113 @@
+package permuvals
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How much a Logger writes; each level includes the ones above it
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+	// Write nothing
+	LogOff
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l LogLevel) String() string {
+	if l < LogDebug || l > LogOff {
+		return fmt.Sprintf("LogLevel(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+// Parse a level name as written by LogLevel.String, ignoring case
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogOff, fmt.Errorf("unknown log level %q; use one of %v", s, strings.Join(logLevelNames, ", "))
+}
+
+// Writes diagnostic messages at or above Level to W, one per line. A nil
+// *Logger writes nothing. Diagnostics never go to the data output; by default
+// they go to stderr.
+type Logger struct {
+	W io.Writer
+	Level LogLevel
+	mu *sync.Mutex
+}
+
+func NewLogger(w io.Writer, level LogLevel) *Logger {
+	return &Logger{W: w, Level: level, mu: &sync.Mutex{}}
+}
+
+// A logger writing to the same place as l, at a different level
+func (l *Logger) WithLevel(level LogLevel) *Logger {
+	if l == nil {
+		return nil
+	}
+	out := *l
+	out.Level = level
+	return &out
+}
+
+func (l *Logger) Enabled(level LogLevel) bool {
+	return l != nil && l.W != nil && level >= l.Level && level < LogOff
+}
+
+func (l *Logger) Logf(level LogLevel, format string, args ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if l.mu != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+	fmt.Fprintf(l.W, "%v permuvals %v: %v\n", time.Now().Format("2006-01-02 15:04:05"), level, strings.TrimSuffix(msg, "\n"))
+}
+
+func (l *Logger) Debugf(format string, args ...interface{}) { l.Logf(LogDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...interface{}) { l.Logf(LogInfo, format, args...) }
+func (l *Logger) Warnf(format string, args ...interface{}) { l.Logf(LogWarn, format, args...) }
+func (l *Logger) Errorf(format string, args ...interface{}) { l.Logf(LogError, format, args...) }
+
+var pkgLogger = NewLogger(os.Stderr, LogWarn)
+
+// The logger used by the package; warnings and errors to stderr unless
+// changed with SetLogger
+func Log() *Logger {
+	return pkgLogger
+}
+
+// Replace the package logger; nil silences it
+func SetLogger(l *Logger) {
+	pkgLogger = l
+}
+
+// The logger asked for by f.LogLevel, or LogDebug if f.Verbose is set
+func LoggerFromFlags(w io.Writer, f Flags) (*Logger, error) {
+	level := LogWarn
+	if f.LogLevel != "" {
+		var err error
+		if level, err = ParseLogLevel(f.LogLevel); err != nil {
+			return nil, err
+		}
+	}
+	if f.Verbose && level > LogDebug {
+		level = LogDebug
+	}
+	return NewLogger(w, level), nil
+}
@@ -0,0 +1,42 @@
+package permuvals
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var b strings.Builder
+	l := NewLogger(&b, LogInfo)
+	l.Debugf("debug %v", 1)
+	l.Infof("info %v", 2)
+	l.Warnf("warn %v\n", 3)
+	l.WithLevel(LogOff).Errorf("error %v", 4)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], "permuvals info: info 2") || !strings.HasSuffix(lines[1], "permuvals warn: warn 3") {
+		t.Errorf("unexpected log output %q", b.String())
+	}
+
+	var nilLogger *Logger
+	nilLogger.Errorf("nothing")
+	if nilLogger.Enabled(LogError) {
+		t.Errorf("nil logger is enabled")
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for _, level := range []LogLevel{LogDebug, LogInfo, LogWarn, LogError, LogOff} {
+		parsed, err := ParseLogLevel(strings.ToUpper(level.String()))
+		if err != nil || parsed != level {
+			t.Errorf("actual and expected do not match. Actual: %v, %v. Expected: %v.", parsed, err, level)
+		}
+	}
+	if _, err := ParseLogLevel("loud"); err == nil {
+		t.Errorf("no error for unknown level")
+	}
+
+	l, err := LoggerFromFlags(nil, Flags{LogLevel: "error", Verbose: true})
+	if err != nil || l.Level != LogDebug {
+		t.Errorf("-v did not give debug logging: %v, %v", l, err)
+	}
+}
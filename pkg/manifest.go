@@ -0,0 +1,290 @@
+package permuvals
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// One input bed as listed in a manifest
+type ManifestEntry struct {
+	Path string
+	// Used as the Bed name, and so in the names of all overlaps
+	Label string
+	// Free text shown with the label in the report and JSON output
+	Group string
+	// PermuteSet is true if the manifest said whether to permute this bed
+	PermuteSet bool
+	Permute bool
+	Options map[string]string
+}
+
+// The list of beds to compare. A manifest file is either the legacy format,
+// with one path per line used as its own label, or a tab-separated table with
+// the columns:
+//
+//	path  label  [group]  [permute]  [options]
+//
+// where permute is a boolean (true/false, yes/no, 1/0), and options is a
+// comma-separated list of key=value pairs. The only option is minlen=N, which
+// drops the spans of the bed shorter than N bp when it is read. A header line starting with
+// "path" is allowed. In both formats, blank lines and lines starting with #
+// are skipped. In the table format, relative paths are relative to the
+// directory containing the manifest.
+type Manifest []ManifestEntry
+
+func parsePermute(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+func ParseManifestOptions(s string) (map[string]string, error) {
+	opts := map[string]string{}
+	if s == "" {
+		return opts, nil
+	}
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || k == "" {
+			return opts, fmt.Errorf("bad manifest option %q; expected key=value", kv)
+		}
+		opts[k] = v
+	}
+	return opts, nil
+}
+
+// Parse one tab-separated manifest line
+func ParseManifestEntry(fields []string, dir string) (e ManifestEntry, err error) {
+	col := func(i int) string {
+		if i < len(fields) {
+			return strings.TrimSpace(fields[i])
+		}
+		return ""
+	}
+	e.Path = col(0)
+	if e.Path == "" {
+		return e, fmt.Errorf("manifest line with no path")
+	}
+	e.Label = col(1)
+	if e.Label == "" {
+		e.Label = e.Path
+	}
+	if !filepath.IsAbs(e.Path) && e.Path != StdinPath {
+		e.Path = filepath.Join(dir, e.Path)
+	}
+	e.Group = col(2)
+	if p := col(3); p != "" && p != "." {
+		e.PermuteSet = true
+		e.Permute, err = parsePermute(p)
+		if err != nil { return }
+	}
+	e.Options, err = ParseManifestOptions(col(4))
+	if err != nil { return }
+	for k := range e.Options {
+		if k != "minlen" {
+			return e, fmt.Errorf("unknown manifest option %q", k)
+		}
+	}
+	_, err = e.MinLength()
+	return
+}
+
+// The minimum span length set by the minlen option, or 0 if it is not set
+func (e ManifestEntry) MinLength() (n int, err error) {
+	s, ok := e.Options["minlen"]
+	if !ok {
+		return 0, nil
+	}
+	n, err = strconv.Atoi(s)
+	if err == nil && n < 0 {
+		err = fmt.Errorf("negative minlen %v", n)
+	}
+	return
+}
+
+// A copy of b without the spans shorter than min bp
+func dropShortSpans(b Bed, min int) Bed {
+	out := MakeBed(b.Name)
+	for _, span := range AllBedSpans(b) {
+		if span.Max - span.Min >= min {
+			out.AddBspans(span)
+		}
+	}
+	return out
+}
+
+// Read a manifest; dir is the directory relative paths are resolved against
+func ReadManifest(r io.Reader, dir string) (m Manifest, err error) {
+	s := bufio.NewScanner(r)
+	table := false
+	first := true
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if first {
+			first = false
+			table = len(fields) > 1
+			if fields[0] == "path" {
+				table = true
+				continue
+			}
+		}
+
+		if !table {
+			m = append(m, ManifestEntry{Path: line, Label: line})
+			continue
+		}
+		var e ManifestEntry
+		e, err = ParseManifestEntry(fields, dir)
+		if err != nil { return }
+		m = append(m, e)
+	}
+	if err = s.Err(); err != nil { return }
+	err = m.checkLabels()
+	return
+}
+
+// Read the manifest at path
+func GetManifest(path string) (m Manifest, err error) {
+	r, err := OpenPath(path)
+	if err != nil { return }
+	defer r.Close()
+	dir := "."
+	if path != StdinPath {
+		dir = filepath.Dir(path)
+	}
+	return ReadManifest(r, dir)
+}
+
+// Labels must be unique, and may not hold the ':' that joins them in subset
+// names or the tabs that separate output columns
+func (m Manifest) checkLabels() error {
+	seen := map[string]struct{}{}
+	for _, e := range m {
+		if strings.ContainsAny(e.Label, ":\t") {
+			return fmt.Errorf("bed label %q contains ':' or a tab", e.Label)
+		}
+		if _, ok := seen[e.Label]; ok {
+			return fmt.Errorf("duplicate bed label %q", e.Label)
+		}
+		seen[e.Label] = struct{}{}
+	}
+	return nil
+}
+
+// Check that labels are valid and that something will be permuted. The
+// manifest's permute column is only checked if toPermute, the beds chosen
+// with -p, is empty.
+func (m Manifest) Validate(toPermute []int) error {
+	if err := m.checkLabels(); err != nil {
+		return err
+	}
+	if len(toPermute) > 0 {
+		return nil
+	}
+	for _, e := range m {
+		if !e.PermuteSet || e.Permute {
+			return nil
+		}
+	}
+	return fmt.Errorf("manifest marks no beds to permute")
+}
+
+func (m Manifest) Paths() []string {
+	paths := make([]string, 0, len(m))
+	for _, e := range m {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+// Read every bed in the manifest, named by its label, with the spans shorter
+// than its minlen option left out
+func (m Manifest) Beds() (beds Beds, err error) {
+	for _, e := range m {
+		var bed Bed
+		bed, err = GetBedPath(e.Path, e.Label)
+		if err != nil { return }
+		var min int
+		min, err = e.MinLength()
+		if err != nil { return }
+		if min > 0 {
+			bed = dropShortSpans(bed, min)
+		}
+		beds = append(beds, bed)
+	}
+	return
+}
+
+// The indices of the beds to permute, in the form used by Flags.ToPermute.
+// If no entry has a permute column, this is nil, meaning permute everything;
+// otherwise entries without one are permuted.
+func (m Manifest) ToPermute() []int {
+	anySet := false
+	var out []int
+	for i, e := range m {
+		if e.PermuteSet {
+			anySet = true
+		}
+		if !e.PermuteSet || e.Permute {
+			out = append(out, i)
+		}
+	}
+	if !anySet {
+		return nil
+	}
+	return out
+}
+
+// Parse command line arguments naming beds, each either a path or label=path
+func ManifestFromArgs(args []string) (m Manifest, err error) {
+	for _, arg := range args {
+		e := ManifestEntry{Path: arg, Label: arg, Options: map[string]string{}}
+		if label, path, ok := strings.Cut(arg, "="); ok && label != "" && path != "" && !strings.ContainsRune(label, filepath.Separator) {
+			e.Path, e.Label = path, label
+		}
+		m = append(m, e)
+	}
+	err = m.checkLabels()
+	return
+}
+
+// Combine the -b manifest, if any, with beds given as arguments, in that
+// order, so that ToPermute indices refer to the combined list
+func GetInputs(flags Flags) (m Manifest, err error) {
+	if flags.BedPaths != "" {
+		m, err = GetManifest(flags.BedPaths)
+		if err != nil { return }
+	}
+	args, err := ManifestFromArgs(flags.BedArgs)
+	if err != nil { return }
+	m = append(m, args...)
+	if len(m) < 1 {
+		return m, fmt.Errorf("no bed files given")
+	}
+	if err = checkIndices("-p", flags.ToPermute, len(m)); err != nil { return }
+	if err = checkIndices("-window-beds", flags.WindowBeds, len(m)); err != nil { return }
+	err = m.Validate(flags.ToPermute)
+	return
+}
+
+// Check that the indices given to flag refer to one of nbeds beds
+func checkIndices(flag string, indices []int, nbeds int) error {
+	for _, i := range indices {
+		if i < 0 || i >= nbeds {
+			return usageErrorf("%v index %v is out of range for %v beds", flag, i, nbeds)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package permuvals
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadManifestLegacy(t *testing.T) {
+	in := "a.bed\n\n# comment\n/data/b.bed\n"
+	m, err := ReadManifest(strings.NewReader(in), "/manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a.bed", "/data/b.bed"}
+	if len(m) != len(expected) {
+		t.Fatalf("manifest %v does not match %v", m, expected)
+	}
+	for i, e := range m {
+		if e.Path != expected[i] || e.Label != expected[i] {
+			t.Errorf("entry %v does not match %v", e, expected[i])
+		}
+	}
+	if m.ToPermute() != nil {
+		t.Errorf("legacy manifest should permute everything, got %v", m.ToPermute())
+	}
+}
+
+func TestReadManifestTable(t *testing.T) {
+	in := "path\tlabel\tgroup\tpermute\toptions\n" +
+		"peaks/a.bed.gz\tA\tchip\tyes\n" +
+		"# comment\n" +
+		"/data/enh.bed\tenh\t\tno\tminlen=100\n" +
+		"c.bed\n"
+	m, err := ReadManifest(strings.NewReader(in), "/manifests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Manifest {
+		ManifestEntry{Path: "/manifests/peaks/a.bed.gz", Label: "A", Group: "chip", PermuteSet: true, Permute: true},
+		ManifestEntry{Path: "/data/enh.bed", Label: "enh", PermuteSet: true, Permute: false},
+		ManifestEntry{Path: "/manifests/c.bed", Label: "c.bed"},
+	}
+	if len(m) != len(expected) {
+		t.Fatalf("manifest %v does not match %v", m, expected)
+	}
+	for i, e := range m {
+		x := expected[i]
+		if e.Path != x.Path || e.Label != x.Label || e.Group != x.Group || e.PermuteSet != x.PermuteSet || e.Permute != x.Permute {
+			t.Errorf("entry %v does not match %v", e, x)
+		}
+	}
+	if n, err := m[1].MinLength(); err != nil || n != 100 {
+		t.Errorf("bad options %v", m[1].Options)
+	}
+	toperm := m.ToPermute()
+	if len(toperm) != 2 || toperm[0] != 0 || toperm[1] != 2 {
+		t.Errorf("ToPermute %v != [0 2]", toperm)
+	}
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	bad := []string {
+		"a.bed\tA\nb.bed\tA\n",
+		"a.bed\tA\t\tmaybe\n",
+		"a.bed\tA\t\t\tnovalue\n",
+		"a.bed\tA\t\t\tstrand=+\n",
+		"a.bed\tA\t\t\tminlen=x\n",
+		"a.bed\tA\t\t\tminlen=-1\n",
+		"a.bed\tA:B\n",
+	}
+	for _, in := range bad {
+		if _, err := ReadManifest(strings.NewReader(in), "."); err == nil {
+			t.Errorf("manifest %q should not parse", in)
+		}
+	}
+}
+
+func TestGetBedsManifest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "first.bed"), []byte("one\t2\t7\none\t99\t110\ntwo\t0\t11\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mpath := filepath.Join(dir, "manifest.tsv")
+	if err := os.WriteFile(mpath, []byte("first.bed\tfirst\nfirst.bed\tlong\t\t\tminlen=10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	beds, err := GetBeds(mpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(beds) != 2 || beds[0].Name != "first" || beds[1].Name != "long" {
+		t.Fatalf("unexpected beds %v", beds)
+	}
+	if n := len(AllBedSpans(beds[0])); n != 3 {
+		t.Errorf("bed has %v spans, expected 3", n)
+	}
+	// minlen=10 drops the 5 bp span
+	if n := len(AllBedSpans(beds[1])); n != 2 {
+		t.Errorf("bed with minlen has %v spans, expected 2", n)
+	}
+}
+
+func TestGetInputsArgs(t *testing.T) {
+	dir := t.TempDir()
+	mpath := filepath.Join(dir, "manifest.tsv")
+	if err := os.WriteFile(mpath, []byte("a.bed\tA\t\tno\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := GetInputs(Flags{BedPaths: mpath, BedArgs: []string{"B=x/b.bed", "c.bed", "dir/x=y.bed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ManifestEntry {
+		ManifestEntry{Path: filepath.Join(dir, "a.bed"), Label: "A"},
+		ManifestEntry{Path: "x/b.bed", Label: "B"},
+		ManifestEntry{Path: "c.bed", Label: "c.bed"},
+		ManifestEntry{Path: "dir/x=y.bed", Label: "dir/x=y.bed"},
+	}
+	if len(m) != len(expected) {
+		t.Fatalf("inputs %v do not match %v", m, expected)
+	}
+	for i, e := range m {
+		if e.Path != expected[i].Path || e.Label != expected[i].Label {
+			t.Errorf("entry %v does not match %v", e, expected[i])
+		}
+	}
+	toperm := m.ToPermute()
+	if len(toperm) != 3 || toperm[0] != 1 {
+		t.Errorf("ToPermute %v != [1 2 3]", toperm)
+	}
+
+	if _, err := GetInputs(Flags{BedArgs: []string{"A:B=a.bed"}}); err == nil {
+		t.Errorf("a label with ':' should be an error")
+	}
+	if _, err := GetInputs(Flags{BedArgs: []string{"A\tB=a.bed"}}); err == nil {
+		t.Errorf("a label with a tab should be an error")
+	}
+	if _, err := GetInputs(Flags{}); err == nil {
+		t.Errorf("no inputs should be an error")
+	}
+	if _, err := GetInputs(Flags{BedPaths: mpath}); err == nil {
+		t.Errorf("permuting no beds should be an error")
+	}
+	if _, err := GetInputs(Flags{BedPaths: mpath, ToPermute: []int{0}}); err != nil {
+		t.Errorf("-p should override the manifest's permute column: %v", err)
+	}
+}
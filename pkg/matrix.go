@@ -0,0 +1,317 @@
+package permuvals
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+)
+
+// The pairwise fold enrichments and probabilities of a Comparison, with cell
+// [i][j] holding the test of Rows[i] against Cols[j]. With Comparison.Pairs,
+// the row is the query and the column the reference; otherwise the cells come
+// from the subsets of two beds, so the matrix is symmetric. Cells without a
+// test, such as the diagonal, are NaN.
+type PairMatrix struct {
+	Rows []string
+	Cols []string
+	CountFold [][]float64
+	CoveredFold [][]float64
+	CountProb [][]float64
+	CoveredProb [][]float64
+}
+
+// Names of the statistics of a PairMatrix, for PairMatrix.Stat
+var MatrixStats = []string{"count_fold", "covered_fold", "count_prob", "covered_prob"}
+
+// The labels of the beds of c: the manifest labels if there are any, and
+// otherwise the names of the single-bed subsets, or of the beds of the pairs
+func comparisonLabels(c Comparison) (labels []string) {
+	for _, e := range c.Inputs {
+		labels = append(labels, e.Label)
+	}
+	if len(labels) > 0 {
+		return
+	}
+	// no manifest, e.g. a Comparison built by hand
+	for _, ovl := range c.Overlaps {
+		if len(ovl.Components) == 1 {
+			labels = append(labels, ovl.Components[0])
+		}
+	}
+	seen := map[string]struct{}{}
+	for _, l := range labels {
+		seen[l] = struct{}{}
+	}
+	for _, p := range c.Pairs {
+		for _, l := range []string{p.Query, p.Reference} {
+			if _, ok := seen[l]; !ok {
+				seen[l] = struct{}{}
+				labels = append(labels, l)
+			}
+		}
+	}
+	return
+}
+
+func nanMatrix(rows, cols int) [][]float64 {
+	out := make([][]float64, rows)
+	for i := range out {
+		out[i] = make([]float64, cols)
+		for j := range out[i] {
+			out[i][j] = math.NaN()
+		}
+	}
+	return out
+}
+
+// The N×N matrix of the pairwise tests of c's N beds
+func NewPairMatrix(c Comparison) PairMatrix {
+	labels := comparisonLabels(c)
+	n := len(labels)
+	m := PairMatrix {
+		Rows: labels,
+		Cols: labels,
+		CountFold: nanMatrix(n, n),
+		CoveredFold: nanMatrix(n, n),
+		CountProb: nanMatrix(n, n),
+		CoveredProb: nanMatrix(n, n),
+	}
+	index := make(map[string]int, n)
+	for i, l := range labels {
+		index[l] = i
+	}
+	set := func(a, b string, countFold, coveredFold, countProb, coveredProb float64) {
+		i, iok := index[a]
+		j, jok := index[b]
+		if !iok || !jok {
+			return
+		}
+		m.CountFold[i][j], m.CoveredFold[i][j] = countFold, coveredFold
+		m.CountProb[i][j], m.CoveredProb[i][j] = countProb, coveredProb
+	}
+
+	if len(c.Pairs) > 0 {
+		for _, p := range c.Pairs {
+			set(p.Query, p.Reference, p.CountFold(), p.CoveredFold(), p.CountProb, p.CoveredProb)
+		}
+		return m
+	}
+	for _, r := range ReportRows(c) {
+		if len(r.Components) != 2 {
+			continue
+		}
+		a, b := r.Components[0], r.Components[1]
+		set(a, b, r.CountFold(), r.CoveredFold(), r.Prob.CountProb, r.Prob.CoveredProb)
+		set(b, a, r.CountFold(), r.CoveredFold(), r.Prob.CountProb, r.Prob.CoveredProb)
+	}
+	return m
+}
+
+// Whether any cell of m has a fold enrichment
+func (m PairMatrix) tested() bool {
+	for _, row := range m.CoveredFold {
+		for _, f := range row {
+			if !math.IsNaN(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The values of the statistic named name, one of MatrixStats
+func (m PairMatrix) Stat(name string) ([][]float64, error) {
+	switch name {
+	case "count_fold":
+		return m.CountFold, nil
+	case "covered_fold":
+		return m.CoveredFold, nil
+	case "count_prob":
+		return m.CountProb, nil
+	case "covered_prob":
+		return m.CoveredProb, nil
+	}
+	return nil, fmt.Errorf("unknown matrix statistic %q; expected one of %v", name, strings.Join(MatrixStats, ", "))
+}
+
+// log2 of a fold enrichment, limited to ±8 so that folds of 0 and infinity
+// can still be compared
+func log2Fold(fold float64) float64 {
+	if math.IsNaN(fold) {
+		return fold
+	}
+	return math.Max(-8, math.Min(8, math.Log2(fold)))
+}
+
+// Root mean squared difference of a and b over the positions where both have
+// a value; infinite if there are none
+func rowDist(a, b []float64) float64 {
+	sum, n := 0.0, 0
+	for i := range a {
+		if math.IsNaN(a[i]) || math.IsNaN(b[i]) {
+			continue
+		}
+		sum += (a[i] - b[i]) * (a[i] - b[i])
+		n++
+	}
+	if n < 1 {
+		return math.Inf(1)
+	}
+	return math.Sqrt(sum / float64(n))
+}
+
+// An order of the rows of x that puts similar rows next to each other: the
+// leaves of an average-linkage hierarchical clustering of the rows by rowDist
+func clusterOrder(x [][]float64) []int {
+	if len(x) < 1 {
+		return nil
+	}
+	dist := make([][]float64, len(x))
+	for i := range x {
+		dist[i] = make([]float64, len(x))
+		for j := range x {
+			dist[i][j] = rowDist(x[i], x[j])
+		}
+	}
+	linkage := func(a, b []int) float64 {
+		sum := 0.0
+		for _, i := range a {
+			for _, j := range b {
+				sum += dist[i][j]
+			}
+		}
+		return sum / float64(len(a) * len(b))
+	}
+
+	clusters := make([][]int, 0, len(x))
+	for i := range x {
+		clusters = append(clusters, []int{i})
+	}
+	for len(clusters) > 1 {
+		bi, bj := 0, 1
+		best := linkage(clusters[0], clusters[1])
+		for i := range clusters {
+			for j := i + 1; j < len(clusters); j++ {
+				if d := linkage(clusters[i], clusters[j]); d < best {
+					bi, bj, best = i, j, d
+				}
+			}
+		}
+		clusters[bi] = append(clusters[bi], clusters[bj]...)
+		clusters = append(clusters[:bj], clusters[bj+1:]...)
+	}
+	return clusters[0]
+}
+
+func transpose(x [][]float64) [][]float64 {
+	if len(x) < 1 {
+		return nil
+	}
+	out := make([][]float64, len(x[0]))
+	for j := range out {
+		out[j] = make([]float64, len(x))
+		for i := range x {
+			out[j][i] = x[i][j]
+		}
+	}
+	return out
+}
+
+// A copy of m with its rows and its columns each reordered by hierarchical
+// clustering of their log2 coverage fold enrichments, so that beds with
+// similar enrichment profiles are next to each other
+func (m PairMatrix) Clustered() PairMatrix {
+	logs := make([][]float64, len(m.CoveredFold))
+	for i, row := range m.CoveredFold {
+		for _, f := range row {
+			logs[i] = append(logs[i], log2Fold(f))
+		}
+	}
+	rows, cols := clusterOrder(logs), clusterOrder(transpose(logs))
+	reorder := func(x [][]float64) [][]float64 {
+		out := make([][]float64, 0, len(rows))
+		for _, i := range rows {
+			row := make([]float64, 0, len(cols))
+			for _, j := range cols {
+				row = append(row, x[i][j])
+			}
+			out = append(out, row)
+		}
+		return out
+	}
+	out := PairMatrix {
+		CountFold: reorder(m.CountFold),
+		CoveredFold: reorder(m.CoveredFold),
+		CountProb: reorder(m.CountProb),
+		CoveredProb: reorder(m.CoveredProb),
+	}
+	for _, i := range rows {
+		out.Rows = append(out.Rows, m.Rows[i])
+	}
+	for _, j := range cols {
+		out.Cols = append(out.Cols, m.Cols[j])
+	}
+	return out
+}
+
+// Write values as a table with a header line of the column names, and the
+// row name at the start of each line
+func FprintMatrixTSV(w io.Writer, rows, cols []string, values [][]float64) {
+	fmt.Fprintf(w, "name\t%v\n", strings.Join(cols, "\t"))
+	for i, row := range values {
+		fmt.Fprint(w, rows[i])
+		for _, v := range row {
+			fmt.Fprintf(w, "\t%v", v)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// The colour of a log2 fold enrichment: white at 0, shading to red at 3 or
+// more and to blue at -3 or less, and grey for NaN
+func heatColor(log2 float64) string {
+	if math.IsNaN(log2) {
+		return "#eeeeee"
+	}
+	t := math.Max(-1, math.Min(1, log2 / 3))
+	fade := int(255 * (1 - math.Abs(t)))
+	if t > 0 {
+		return fmt.Sprintf("#ff%02x%02x", fade, fade)
+	}
+	return fmt.Sprintf("#%02x%02xff", fade, fade)
+}
+
+// An SVG heatmap of m's coverage fold enrichments on a log2 scale, with a *
+// in each cell with a coverage probability below 0.05
+func HeatmapSVG(title string, m PairMatrix) template.HTML {
+	const cell, labelW = 24.0, 120.0
+	width := labelW + cell * float64(len(m.Cols)) + svgMargin
+	height := svgMargin + labelW + cell * float64(len(m.Rows)) + svgMargin
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f">`, width, height)
+	b.WriteString(svgText(labelW, 14, "start", title))
+
+	top := svgMargin + labelW
+	for j, col := range m.Cols {
+		x, y := labelW + cell * (float64(j) + 0.6), top - 6
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" transform="rotate(-60 %.1f %.1f)" font-size="11">%v</text>`, x, y, x, y, template.HTMLEscapeString(col))
+	}
+	for i, row := range m.Rows {
+		y := top + cell * float64(i)
+		b.WriteString(svgText(labelW - 6, y + cell * 0.7, "end", row))
+		for j, col := range m.Cols {
+			x := labelW + cell * float64(j)
+			fold, prob := m.CoveredFold[i][j], m.CoveredProb[i][j]
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%v" stroke="white"><title>%v, %v: fold %.3g, p %.3g</title></rect>`,
+				x, y, cell, cell, heatColor(log2Fold(fold)), template.HTMLEscapeString(row), template.HTMLEscapeString(col), fold, prob)
+			if prob < 0.05 {
+				b.WriteString(svgText(x + cell / 2, y + cell * 0.75, "middle", "*"))
+			}
+		}
+	}
+	b.WriteString(svgText(labelW, top + cell * float64(len(m.Rows)) + 16, "start", "log2 fold: blue < 0 < red; * p < 0.05"))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
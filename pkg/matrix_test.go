@@ -0,0 +1,93 @@
+package permuvals
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestNewPairMatrix(t *testing.T) {
+	c := testComparison()
+	c.Pairs = nil
+	m := NewPairMatrix(c)
+	if !sameStrings(m.Rows, []string{"first", "second"}) || !sameStrings(m.Cols, m.Rows) {
+		t.Fatalf("unexpected labels %v, %v", m.Rows, m.Cols)
+	}
+	var row ReportRow
+	for _, r := range ReportRows(c) {
+		if r.Name == "first:second" {
+			row = r
+		}
+	}
+	if m.CoveredFold[0][1] != row.CoveredFold() || m.CoveredFold[1][0] != row.CoveredFold() || m.CountProb[1][0] != row.Prob.CountProb {
+		t.Errorf("matrix %v does not match subset %v", m, row)
+	}
+	if !math.IsNaN(m.CoveredFold[0][0]) {
+		t.Errorf("diagonal %v is not NaN", m.CoveredFold[0][0])
+	}
+
+	// pairwise tests are not symmetric
+	c.Pairs = PairStats {
+		PairStat{Query: "first", Reference: "second", Count: 2, NullCount: []int{1}, CountProb: 0.5},
+		PairStat{Query: "second", Reference: "first", Count: 3, NullCount: []int{1}, CountProb: 0.25},
+	}
+	m = NewPairMatrix(c)
+	if m.CountFold[0][1] != 2 || m.CountFold[1][0] != 3 || m.CountProb[1][0] != 0.25 {
+		t.Errorf("unexpected pairwise matrix %v", m.CountFold)
+	}
+	// a pairwise run has no subsets, so without a manifest the labels come
+	// from the pairs
+	c.Inputs, c.Overlaps = nil, nil
+	if m := NewPairMatrix(c); !sameStrings(m.Rows, []string{"first", "second"}) || m.CountFold[1][0] != 3 {
+		t.Errorf("unexpected pairwise matrix without a manifest %v, %v", m.Rows, m.CountFold)
+	}
+	if _, err := m.Stat("nonsense"); err == nil {
+		t.Errorf("unknown statistic should be an error")
+	}
+}
+
+func TestClusterOrder(t *testing.T) {
+	x := [][]float64{{0, 0}, {5, 5}, {0.1, 0}, {5, math.NaN()}}
+	order := clusterOrder(x)
+	pos := make(map[int]int)
+	for i, row := range order {
+		pos[row] = i
+	}
+	if len(order) != 4 || math.Abs(float64(pos[0] - pos[2])) != 1 || math.Abs(float64(pos[1] - pos[3])) != 1 {
+		t.Errorf("similar rows are not next to each other: %v", order)
+	}
+
+	m := PairMatrix {
+		Rows: []string{"a", "b", "c"},
+		Cols: []string{"a", "b", "c"},
+		CoveredFold: [][]float64{{math.NaN(), 8, 1}, {8, math.NaN(), 1}, {1, 1, math.NaN()}},
+	}
+	m.CountFold, m.CountProb, m.CoveredProb = m.CoveredFold, m.CoveredFold, m.CoveredFold
+	cm := m.Clustered()
+	for i, r := range cm.Rows {
+		for j, c := range cm.Cols {
+			x, y := indexOf(m.Rows, r), indexOf(m.Cols, c)
+			if v, e := cm.CoveredFold[i][j], m.CoveredFold[x][y]; v != e && !(math.IsNaN(v) && math.IsNaN(e)) {
+				t.Errorf("clustered %v, %v: actual and expected do not match. Actual: %v. Expected: %v.", r, c, v, e)
+			}
+		}
+	}
+}
+
+func indexOf(x []string, s string) int {
+	for i, v := range x {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestHeatmapSVGWellFormed(t *testing.T) {
+	m := NewPairMatrix(testComparison())
+	svg := string(HeatmapSVG("a<b", m.Clustered()))
+	checkWellFormedSVG(t, svg)
+	if !strings.Contains(svg, "first") {
+		t.Errorf("heatmap is missing its labels:\n%v", svg)
+	}
+}
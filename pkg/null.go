@@ -0,0 +1,48 @@
+package permuvals
+
+import (
+	"fmt"
+	"io"
+)
+
+// Columns of the long-format null distribution, one row per subset per permutation
+const NullLongHeader = "iteration\tname\tn_components\tcount\tcovered"
+
+// Write the null distribution in long format, suitable for loading into R or
+// pandas. Iterations are numbered from 0.
+func FprintNullLong(w io.Writer, counts OverlapCounts) {
+	for _, c := range counts {
+		for i := range c.Count {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", i, c.Name, len(c.Components), c.Count[i], c.Covered[i])
+		}
+	}
+}
+
+// The long-format null distribution as Arrow columns, named as in NullLongHeader
+func NullArrowColumns(counts OverlapCounts) []ArrowColumn {
+	n := 0
+	for _, c := range counts {
+		n += len(c.Count)
+	}
+	iteration := ArrowColumn{Name: "iteration", Int64s: make([]int64, 0, n)}
+	name := ArrowColumn{Name: "name", Strings: make([]string, 0, n)}
+	ncomp := ArrowColumn{Name: "n_components", Int64s: make([]int64, 0, n)}
+	count := ArrowColumn{Name: "count", Int64s: make([]int64, 0, n)}
+	covered := ArrowColumn{Name: "covered", Int64s: make([]int64, 0, n)}
+	for _, c := range counts {
+		for i := range c.Count {
+			iteration.Int64s = append(iteration.Int64s, int64(i))
+			name.Strings = append(name.Strings, c.Name)
+			ncomp.Int64s = append(ncomp.Int64s, int64(len(c.Components)))
+			count.Int64s = append(count.Int64s, int64(c.Count[i]))
+			covered.Int64s = append(covered.Int64s, int64(c.Covered[i]))
+		}
+	}
+	return []ArrowColumn{iteration, name, ncomp, count, covered}
+}
+
+// Write the long-format null distribution as an Arrow IPC stream, readable
+// with e.g. pyarrow.ipc.open_stream or arrow::read_ipc_stream
+func WriteNullArrow(w io.Writer, counts OverlapCounts) error {
+	return WriteArrowStream(w, NullArrowColumns(counts))
+}
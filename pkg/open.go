@@ -0,0 +1,70 @@
+package permuvals
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// The path that means "read from standard input"
+const StdinPath = "-"
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	under io.Closer
+}
+
+func (g gzipReadCloser) Close() error {
+	err := g.Reader.Close()
+	if uerr := g.under.Close(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// Wraps a reader so that Close does nothing; used for stdin
+type nopReadCloser struct {
+	io.Reader
+}
+
+func (nopReadCloser) Close() error { return nil }
+
+// Report whether the next bytes in r are the gzip magic number. Plain gzip
+// and bgzip both start with it.
+func isGzip(r *bufio.Reader) bool {
+	magic, err := r.Peek(2)
+	if err != nil {
+		return false
+	}
+	return magic[0] == 0x1f && magic[1] == 0x8b
+}
+
+// Open a path for reading, decompressing it if needed. The path "-" reads
+// from standard input, which is not closed when the result is closed.
+func OpenPath(path string) (io.ReadCloser, error) {
+	var under io.ReadCloser
+	if path == StdinPath {
+		under = nopReadCloser{os.Stdin}
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		under = f
+	}
+
+	br := bufio.NewReader(under)
+	if !isGzip(br) {
+		return struct {
+			io.Reader
+			io.Closer
+		}{br, under}, nil
+	}
+	gr, err := gzip.NewReader(br)
+	if err != nil {
+		under.Close()
+		return nil, err
+	}
+	return gzipReadCloser{gr, under}, nil
+}
@@ -0,0 +1,68 @@
+package permuvals
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeGzip(t *testing.T, path string, members ...string) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Separate gzip members, the way bgzip writes blocks
+	for _, m := range members {
+		gw := gzip.NewWriter(f)
+		if _, err := gw.Write([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+		if err := gw.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGetBedsGzip(t *testing.T) {
+	dir := t.TempDir()
+	// no .gz extension: detection must use the magic bytes
+	bedpath := filepath.Join(dir, "peaks.bed")
+	writeGzip(t, bedpath, "one\t2\t7\n", "one\t99\t110\ntwo\t0\t11\n")
+	listpath := filepath.Join(dir, "beds.txt.gz")
+	writeGzip(t, listpath, bedpath+"\n")
+
+	beds, err := GetBeds(listpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(beds) != 1 {
+		t.Fatalf("len(beds) %v != 1", len(beds))
+	}
+	spans := AllBedSpans(beds[0])
+	expected := in1Bspans()
+	if len(spans) != len(expected) {
+		t.Fatalf("actual and expected do not match. Actual: %v. Expected: %v.", spans, expected)
+	}
+	for i, b := range spans {
+		if b != expected[i] {
+			t.Errorf("actual and expected do not match. Actual: %v. Expected: %v.", spans, expected)
+		}
+	}
+}
+
+func TestOpenPathPlain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "g.bed")
+	if err := os.WriteFile(path, []byte("one\t0\t200\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	genome, err := GetGenome(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := AllBedSpans(genome)
+	if len(spans) != 1 || spans[0] != MakeBspan("one", 0, 200) {
+		t.Errorf("unexpected genome spans %v", spans)
+	}
+}
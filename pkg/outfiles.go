@@ -0,0 +1,108 @@
+package permuvals
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Column headers for the files written by WriteComparisonFiles
+const (
+	OvlsBedHeader = "#chrom\tstart\tend\tn_components\twidth\tcovered\tname"
+	ProbsHeader = "count_prob\tcovered_prob\tname"
+)
+
+// Create path, call write on a buffered writer for it, and close it
+func writeFile(path string, write func(w io.Writer) error) (err error) {
+	f, err := os.Create(path)
+	if err != nil { return }
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	w := bufio.NewWriter(f)
+	if err = write(w); err != nil { return }
+	return w.Flush()
+}
+
+// Write each part of c to its own file, named by appending to prefix:
+// overlaps.bed, probs.tsv, null_counts.tsv (in long format; see
+// FprintNullLong), meta.json, pairs.tsv if c has pairwise tests, and
+// distances.tsv and reldist.tsv (see FprintRelDistHists) if c has distances.
+// Each table starts with a header line. If arrow is set, the null
+// distribution is also written as null_counts.arrow. If prefix names a
+// directory (ends in a slash), it is created.
+func WriteComparisonFiles(prefix string, c Comparison, arrow bool) error {
+	if err := os.MkdirAll(filepath.Dir(prefix + "x"), 0755); err != nil {
+		return err
+	}
+	err := writeFile(prefix + "overlaps.bed", func(w io.Writer) error {
+		fmt.Fprintln(w, OvlsBedHeader)
+		FprintOvlsBed(w, c.Overlaps)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = writeFile(prefix + "probs.tsv", func(w io.Writer) error {
+		fmt.Fprintln(w, ProbsHeader)
+		FprintProbs(w, c.Probs)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = writeFile(prefix + "null_counts.tsv", func(w io.Writer) error {
+		fmt.Fprintln(w, NullLongHeader)
+		FprintNullLong(w, c.IterCounts)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(c.Pairs) > 0 {
+		err = writeFile(prefix + "pairs.tsv", func(w io.Writer) error {
+			fmt.Fprintln(w, PairsHeader)
+			FprintPairs(w, c.Pairs)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if len(c.Distances) > 0 {
+		err = writeFile(prefix + "distances.tsv", func(w io.Writer) error {
+			fmt.Fprintln(w, DistancesHeader)
+			FprintDistances(w, c.Distances)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = writeFile(prefix + "reldist.tsv", func(w io.Writer) error {
+			fmt.Fprintln(w, RelDistHeader)
+			FprintRelDistHists(w, c.Distances)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if arrow {
+		err = writeFile(prefix + "null_counts.arrow", func(w io.Writer) error {
+			return WriteNullArrow(w, c.IterCounts)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return writeFile(prefix + "meta.json", func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		return enc.Encode(jsonComparison{Schema: ComparisonSchema, Type: "meta", Meta: encodeMeta(c)})
+	})
+}
@@ -0,0 +1,58 @@
+package permuvals
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteComparisonFiles(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "out") + "/run1_"
+	c := testComparison()
+	if err := WriteComparisonFiles(prefix, c, true); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]struct{header string; lines int} {
+		"overlaps.bed": {OvlsBedHeader, 1 + 3},
+		"probs.tsv": {ProbsHeader, 1 + len(c.Probs)},
+		"null_counts.tsv": {NullLongHeader, 1 + 2 * len(c.IterCounts)},
+		"reldist.tsv": {RelDistHeader, 1 + RelDistBins * len(c.Distances)},
+	}
+	for name, x := range expected {
+		data, err := os.ReadFile(prefix + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if lines[0] != x.header || len(lines) != x.lines {
+			t.Errorf("%v: header %q and %v lines, expected %q and %v lines", name, lines[0], len(lines), x.header, x.lines)
+		}
+	}
+	if _, err := os.Stat(prefix + "null_counts.arrow"); err != nil {
+		t.Error(err)
+	}
+	f, err := os.Open(prefix + "meta.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	meta, err := ReadComparisonJSON(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Meta.Rseed != c.Meta.Rseed || len(meta.Inputs) != len(c.Inputs) {
+		t.Errorf("meta %v does not match %v", meta.Meta, c.Meta)
+	}
+}
+
+func TestFprintNullLong(t *testing.T) {
+	c := testComparison()
+	var b strings.Builder
+	FprintNullLong(&b, c.IterCounts)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	expected := "1\tfirst:second\t2\t2\t102"
+	if len(lines) != 2 * len(c.IterCounts) || lines[len(lines) - 1] != expected {
+		t.Errorf("last line %q, expected %q", lines[len(lines) - 1], expected)
+	}
+}
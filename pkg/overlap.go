@@ -0,0 +1,206 @@
+package permuvals
+
+import (
+	"sort"
+)
+
+// How much a span A must overlap a span B for the overlap to count. The zero
+// value counts any overlap of at least 1 bp.
+type MinOverlap struct {
+	// Minimum bp overlapped
+	Bp int
+	// Minimum fraction of A overlapped
+	FracA float64
+	// Minimum fraction of B overlapped
+	FracB float64
+	// Also require FracA of B, like bedtools -r
+	Reciprocal bool
+}
+
+// Whether an overlap of bp between spans of widthA and widthB is enough
+func (m MinOverlap) Passes(bp, widthA, widthB int) bool {
+	fracB := m.FracB
+	if m.Reciprocal && m.FracA > fracB {
+		fracB = m.FracA
+	}
+	return bp > 0 && bp >= m.Bp && float64(bp) >= m.FracA * float64(widthA) && float64(bp) >= fracB * float64(widthB)
+}
+
+// Whether m counts every overlap
+func (m MinOverlap) IsZero() bool {
+	return m.Bp <= 1 && m.FracA <= 0 && m.FracB <= 0
+}
+
+// How the overlaps between beds are found, for the observed beds and every
+// permutation alike
+type OverlapOpts struct {
+	// Maximum number of beds to compare at once; negative means no limit
+	MaxComps int
+	// The subsets to compare, as bed labels resolved by ResolveSubsets,
+	// instead of all subsets of up to MaxComps beds
+	Subsets [][]string
+	// Minimum overlap between the span of the first bed in a subset and the
+	// span of each other bed for their intersection to count
+	Min MinOverlap
+	// Bases to pad the spans of WindowBeds by on each side before finding
+	// overlaps, clipped to the chromosome ends; see PadBeds
+	Window int
+	// Indices of the beds to pad; empty means all
+	WindowBeds []int
+	// Keep only the bases of each subset covered by no other bed; see
+	// ExclusiveOverlaps
+	Exclusive bool
+}
+
+// Copies of beds with the spans of those in opts.WindowBeds padded by
+// opts.Window on each side, clipped to the chromosome ends in genome. The
+// beds are returned as they are if opts.Window is 0.
+func PadBeds(beds Beds, genome Bed, opts OverlapOpts) Beds {
+	if opts.Window == 0 {
+		return beds
+	}
+	out := make(Beds, 0, len(beds))
+	for i, b := range beds {
+		out = append(out, opts.padBed(i, b, genome))
+	}
+	return out
+}
+
+// b, padded as PadBeds pads the bed at index i
+func (opts OverlapOpts) padBed(i int, b Bed, genome Bed) Bed {
+	if opts.Window == 0 {
+		return b
+	}
+	pad := len(opts.WindowBeds) < 1
+	for _, j := range opts.WindowBeds {
+		if j == i {
+			pad = true
+		}
+	}
+	if !pad {
+		return b
+	}
+	return b.Slop(genome, opts.Window, opts.Window)
+}
+
+// The overlaps of beds after padding them with PadBeds, as counted for both
+// the observed and the permuted beds
+func GetWindowOverlaps(beds Beds, genome Bed, opts OverlapOpts) Overlaps {
+	return GetOverlapsOpts(PadBeds(beds, genome, opts), opts)
+}
+
+// The spans of each chromosome of b, sorted
+func sortedBspans(b Bed) map[string][]Bspan {
+	out := make(map[string][]Bspan, len(b.Chroms))
+	for _, chrom := range b.Chroms {
+		out[chrom] = AllBspans(chrom, b.Intervals[chrom])
+	}
+	return out
+}
+
+// The span of sorted containing x, which must be inside one
+func containing(x Bspan, sorted []Bspan) Bspan {
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Max > x.Min })
+	return sorted[i]
+}
+
+// Keep the spans of ovl that pass min. Each span of an intersection lies in
+// exactly one span of each component bed, since the spans of a bed are
+// merged; the first component's span is A, and each other component's span
+// is a B.
+func filterOverlap(ovl Overlap, components []map[string][]Bspan, min MinOverlap) Overlap {
+	out := Overlap{MakeBed(ovl.Name), ovl.Components}
+	for _, chrom := range ovl.Chroms {
+		var kept []Bspan
+		for _, span := range AllBspans(chrom, ovl.Intervals[chrom]) {
+			a := containing(span, components[0][chrom])
+			pass := true
+			for _, c := range components[1:] {
+				b := containing(span, c[chrom])
+				if !min.Passes(span.Width(), a.Width(), b.Width()) {
+					pass = false
+					break
+				}
+			}
+			if pass {
+				kept = append(kept, span)
+			}
+		}
+		out.addSortedBspans(chrom, kept)
+	}
+	return out
+}
+
+// GetOverlaps (or SelectedOverlaps, with opts.Subsets), then drop the parts
+// of intersections that do not pass opts.Min, and make them exclusive if
+// opts.Exclusive is set
+func GetOverlapsOpts(beds Beds, opts OverlapOpts) Overlaps {
+	var ovls Overlaps
+	if len(opts.Subsets) > 0 {
+		ovls = SelectedOverlaps(beds, opts.Subsets)
+	} else {
+		ovls = GetOverlaps(beds, opts.MaxComps)
+	}
+	if !opts.Min.IsZero() {
+		ovls = filterOverlaps(ovls, beds, opts.Min)
+	}
+	if opts.Exclusive {
+		ovls = ExclusiveOverlaps(ovls, beds)
+	}
+	return ovls
+}
+
+// Make each overlap in ovls exclusive, as in an UpSet plot: remove the bases
+// covered by any of beds that is not one of its components. Single-bed
+// subsets, which GetOverlap leaves empty, get the bases covered by that bed
+// alone. If ovls has every subset of beds, the exclusive overlaps partition
+// the bases covered by any bed. ovls is changed in place.
+func ExclusiveOverlaps(ovls Overlaps, beds Beds) Overlaps {
+	byName := make(map[string]Bed, len(beds))
+	for _, b := range beds {
+		byName[b.Name] = b
+	}
+	for i, ovl := range ovls {
+		if len(ovl.Components) < 1 {
+			continue
+		}
+		if len(ovl.Components) == 1 {
+			// copy the bed, to subtract from it in place
+			src := byName[ovl.Components[0]]
+			ovl = Overlap{MakeBed(ovl.Name), ovl.Components}
+			for _, chrom := range src.Chroms {
+				ovl.addSortedBspans(chrom, AllBspans(chrom, src.Intervals[chrom]))
+			}
+		}
+		in := make(map[string]struct{}, len(ovl.Components))
+		for _, name := range ovl.Components {
+			in[name] = struct{}{}
+		}
+		for _, b := range beds {
+			if _, ok := in[b.Name]; !ok {
+				ovl.SubtractBed(b)
+			}
+		}
+		ovls[i] = ovl
+	}
+	return ovls
+}
+
+// Keep the parts of the intersections in ovls that pass min; see filterOverlap
+func filterOverlaps(ovls Overlaps, beds Beds, min MinOverlap) Overlaps {
+	spans := make(map[string]map[string][]Bspan, len(beds))
+	for _, b := range beds {
+		spans[b.Name] = sortedBspans(b)
+	}
+	for i, ovl := range ovls {
+		if len(ovl.Components) < 2 {
+			continue
+		}
+		components := make([]map[string][]Bspan, 0, len(ovl.Components))
+		for _, name := range ovl.Components {
+			components = append(components, spans[name])
+		}
+		ovls[i] = filterOverlap(ovl, components, min)
+	}
+	return ovls
+}
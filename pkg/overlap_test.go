@@ -0,0 +1,126 @@
+package permuvals
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMinOverlapPasses(t *testing.T) {
+	cases := []struct {
+		min MinOverlap
+		bp, widthA, widthB int
+		expected bool
+	} {
+		{MinOverlap{}, 0, 10, 10, false},
+		{MinOverlap{}, 1, 10, 10, true},
+		{MinOverlap{Bp: 5}, 4, 10, 10, false},
+		{MinOverlap{Bp: 5}, 5, 10, 10, true},
+		{MinOverlap{FracA: 0.5}, 5, 10, 100, true},
+		{MinOverlap{FracA: 0.5}, 5, 11, 100, false},
+		{MinOverlap{FracB: 0.5}, 5, 100, 10, true},
+		{MinOverlap{FracB: 0.5}, 5, 10, 100, false},
+		{MinOverlap{FracA: 0.5, Reciprocal: true}, 5, 10, 100, false},
+		{MinOverlap{FracA: 0.5, Reciprocal: true}, 5, 10, 10, true},
+	}
+	for _, c := range cases {
+		if actual := c.min.Passes(c.bp, c.widthA, c.widthB); actual != c.expected {
+			t.Errorf("%+v.Passes(%v, %v, %v): actual and expected do not match. Actual: %v. Expected: %v.", c.min, c.bp, c.widthA, c.widthB, actual, c.expected)
+		}
+	}
+}
+
+func TestGetOverlapsOpts(t *testing.T) {
+	beds := Beds{toBed("first", in1Bspans()), toBed("second", in2Bspans())}
+	// the intersections are one [5,7), one [99,105) and two [3,11), inside
+	// spans of first 5, 11 and 11 wide, and of second 17, 25 and 17 wide
+	cases := []struct {
+		min MinOverlap
+		expected []Bspan
+	} {
+		{MinOverlap{}, []Bspan{MakeBspan("one", 5, 7), MakeBspan("one", 99, 105), MakeBspan("two", 3, 11)}},
+		{MinOverlap{Bp: 6}, []Bspan{MakeBspan("one", 99, 105), MakeBspan("two", 3, 11)}},
+		{MinOverlap{FracA: 0.5}, []Bspan{MakeBspan("one", 99, 105), MakeBspan("two", 3, 11)}},
+		{MinOverlap{FracB: 0.4}, []Bspan{MakeBspan("two", 3, 11)}},
+		{MinOverlap{FracA: 0.3, Reciprocal: true}, []Bspan{MakeBspan("two", 3, 11)}},
+	}
+	for _, c := range cases {
+		ovls := GetOverlapsOpts(beds, OverlapOpts{MaxComps: -1, Min: c.min})
+		if len(ovls) != 4 || ovls[3].Name != "first:second" {
+			t.Fatalf("unexpected overlaps %v", ovls)
+		}
+		checkBspans(t, AllBedSpans(ovls[3].Bed), c.expected)
+	}
+}
+
+func TestPermuteOptsMin(t *testing.T) {
+	beds, genome := checkpointInputs()
+	opts := OverlapOpts{MaxComps: -1, Min: MinOverlap{FracA: 0.5, FracB: 0.5}}
+	all := CountPermutations(Permutations(beds, genome, 50, rand.New(rand.NewSource(2)), -1, nil))
+	min := CountPermutations(PermutationsOpts(beds, genome, 50, rand.New(rand.NewSource(2)), nil, opts))
+	fewer := false
+	for i, count := range min[3].Count {
+		if count > all[3].Count[i] {
+			t.Errorf("permutation %v has %v thresholded overlaps, more than %v in all", i, count, all[3].Count[i])
+		}
+		if count < all[3].Count[i] {
+			fewer = true
+		}
+	}
+	if !fewer {
+		t.Errorf("thresholds removed no permuted overlaps")
+	}
+}
+
+func TestWindowOverlaps(t *testing.T) {
+	a := toBed("a", []Bspan{MakeBspan("one", 2, 7), MakeBspan("one", 150, 160)})
+	b := toBed("b", []Bspan{MakeBspan("one", 20, 30), MakeBspan("one", 185, 195)})
+	genome := toBed("genome", genomeBspans())
+
+	padded := PadBeds(Beds{a, b}, genome, OverlapOpts{Window: 15, WindowBeds: []int{0}})
+	checkBspans(t, AllBedSpans(padded[0]), []Bspan{MakeBspan("one", 0, 22), MakeBspan("one", 135, 175)})
+	checkBspans(t, AllBedSpans(padded[1]), AllBedSpans(b))
+
+	ovls := GetWindowOverlaps(Beds{a, b}, genome, OverlapOpts{MaxComps: -1, Window: 15})
+	checkBspans(t, AllBedSpans(ovls[3].Bed), []Bspan{MakeBspan("one", 5, 22), MakeBspan("one", 170, 175)})
+	checkBspans(t, AllBedSpans(a), []Bspan{MakeBspan("one", 2, 7), MakeBspan("one", 150, 160)})
+}
+
+func TestExclusiveOverlaps(t *testing.T) {
+	beds := Beds {
+		toBed("a", []Bspan{MakeBspan("one", 0, 10)}),
+		toBed("b", []Bspan{MakeBspan("one", 5, 15)}),
+		toBed("c", []Bspan{MakeBspan("one", 8, 20), MakeBspan("two", 0, 5)}),
+	}
+	expected := map[string][]Bspan {
+		"a": []Bspan{MakeBspan("one", 0, 5)},
+		"b": nil,
+		"a:b": []Bspan{MakeBspan("one", 5, 8)},
+		"c": []Bspan{MakeBspan("one", 15, 20), MakeBspan("two", 0, 5)},
+		"a:c": nil,
+		"b:c": []Bspan{MakeBspan("one", 10, 15)},
+		"a:b:c": []Bspan{MakeBspan("one", 8, 10)},
+	}
+	ovls := GetOverlapsOpts(beds, OverlapOpts{MaxComps: -1, Exclusive: true})
+	covered := 0
+	for _, ovl := range ovls {
+		if ovl.Name == "" {
+			continue
+		}
+		spans := AllBedSpans(ovl.Bed)
+		checkBspans(t, spans, expected[ovl.Name])
+		covered += Covered(spans)
+	}
+	// the subsets partition the union
+	if union := Covered(AllBedSpans(UnionBeds("union", beds...))); covered != union {
+		t.Errorf("exclusive overlaps cover %v bp, expected the %v of the union", covered, union)
+	}
+	// the beds themselves are unchanged
+	checkBspans(t, AllBedSpans(beds[2]), []Bspan{MakeBspan("one", 8, 20), MakeBspan("two", 0, 5)})
+
+	counts := CountPermutations(PermutationsOpts(beds, toBed("genome", genomeBspans()), 5, rand.New(rand.NewSource(1)), nil, OverlapOpts{MaxComps: -1, Exclusive: true}))
+	for _, count := range counts {
+		if count.Name == "a" && len(count.Count) != 5 {
+			t.Errorf("unexpected null distribution %v", count)
+		}
+	}
+}
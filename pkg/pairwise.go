@@ -0,0 +1,130 @@
+package permuvals
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+)
+
+// The overlap of a query bed with a reference bed, tested against permutations
+// of the query alone, with the reference held fixed. Unlike the subsets of
+// GetOverlaps, Query->Reference and Reference->Query are different tests.
+type PairStat struct {
+	Query string
+	Reference string
+	// Number of spans and bp in the intersection of the beds
+	Count int
+	Covered int
+	// Count and Covered in each permutation of the query
+	NullCount []int
+	NullCovered []int
+	// The fraction of permutations with more overlap than observed
+	CountProb float64
+	CoveredProb float64
+}
+
+type PairStats []PairStat
+
+func (p PairStat) Name() string {
+	return p.Query + "->" + p.Reference
+}
+
+func (p PairStat) CountFold() float64 { return FoldEnrichment(p.Count, p.NullCount) }
+func (p PairStat) CoveredFold() float64 { return FoldEnrichment(p.Covered, p.NullCovered) }
+
+// The intersection of a query and a reference, with the query's spans as A
+// for min
+func pairOverlap(query, reference Bed, min MinOverlap) []Bspan {
+	ovl := GetOverlap(Beds{query, reference})
+	if !min.IsZero() {
+		ovl = filterOverlap(ovl, []map[string][]Bspan{sortedBspans(query), sortedBspans(reference)}, min)
+	}
+	return AllBedSpans(ovl.Bed)
+}
+
+// Call f with every query (the beds in toPermute, or all beds) and the index
+// of every other bed, in the order of PairStats
+func eachPair(beds Beds, toPermute []int, f func(q, r int)) {
+	for _, q := range bedsToPermute(len(beds), toPermute) {
+		for r := range beds {
+			if r != q {
+				f(q, r)
+			}
+		}
+	}
+}
+
+// The overlaps of every query, as in toPermute, with every other bed, without
+// a null distribution. Beds are padded as in PadBeds, and opts.MaxComps is
+// not used.
+func GetPairStats(beds Beds, genome Bed, toPermute []int, opts OverlapOpts) (out PairStats) {
+	padded := PadBeds(beds, genome, opts)
+	eachPair(beds, toPermute, func(q, r int) {
+		spans := pairOverlap(padded[q], padded[r], opts.Min)
+		out = append(out, PairStat {
+			Query: beds[q].Name,
+			Reference: beds[r].Name,
+			Count: len(spans),
+			Covered: Covered(spans),
+		})
+	})
+	return
+}
+
+// Permute each query once, and add its overlaps with every reference to the
+// null distributions of ps, which must come from GetPairStats with the same
+// beds and settings
+func AddPairPermutation(ps PairStats, beds Beds, genome Bed, randgen *rand.Rand, toPermute []int, opts OverlapOpts) PairStats {
+	padded := PadBeds(beds, genome, opts)
+	permuted := make(map[int]Bed)
+	i := 0
+	eachPair(beds, toPermute, func(q, r int) {
+		query, ok := permuted[q]
+		if !ok {
+			query = opts.padBed(q, PermuteBeds(Beds{beds[q]}, genome, randgen, nil)[0], genome)
+			permuted[q] = query
+		}
+		spans := pairOverlap(query, padded[r], opts.Min)
+		ps[i].NullCount = append(ps[i].NullCount, len(spans))
+		ps[i].NullCovered = append(ps[i].NullCovered, Covered(spans))
+		i++
+	})
+	return ps
+}
+
+// The fraction of null more than observed, as in GetProb
+func probAbove(observed int, null []int) float64 {
+	sorted := append([]int{}, null...)
+	sort.Ints(sorted)
+	return float64(len(sorted) - pcount(observed, sorted)) / float64(len(sorted))
+}
+
+// Set the probabilities of ps from their null distributions
+func GetPairProbs(ps PairStats) {
+	for i := range ps {
+		ps[i].CountProb = probAbove(ps[i].Count, ps[i].NullCount)
+		ps[i].CoveredProb = probAbove(ps[i].Covered, ps[i].NullCovered)
+	}
+}
+
+// The overlaps of every query with every other bed, tested against
+// iterations permutations of the query
+func PairPermutations(beds Beds, genome Bed, iterations int, randgen *rand.Rand, toPermute []int, opts OverlapOpts) PairStats {
+	ps := GetPairStats(beds, genome, toPermute, opts)
+	for i := 0; i < iterations; i++ {
+		ps = AddPairPermutation(ps, beds, genome, randgen, toPermute, opts)
+	}
+	GetPairProbs(ps)
+	return ps
+}
+
+// Header of the table written by FprintPairs
+const PairsHeader = "count_prob\tcovered_prob\tname\tcount\tcovered\tcount_fold\tcovered_fold"
+
+// Write one line per query and reference, in the column order of PairsHeader
+func FprintPairs(w io.Writer, ps PairStats) {
+	for _, p := range ps {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", p.CountProb, p.CoveredProb, p.Name(), p.Count, p.Covered, p.CountFold(), p.CoveredFold())
+	}
+}
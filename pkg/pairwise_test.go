@@ -0,0 +1,86 @@
+package permuvals
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGetPairStats(t *testing.T) {
+	beds := Beds{toBed("first", in1Bspans()), toBed("second", in2Bspans())}
+	// the intersections are 2, 6 and 8 bp, inside spans of first 5, 11 and
+	// 11 wide, and of second 17, 25 and 17 wide, so only first is mostly
+	// covered
+	ps := GetPairStats(beds, MakeBed("genome"), nil, OverlapOpts{Min: MinOverlap{FracA: 0.5}})
+	if len(ps) != 2 || ps[0].Name() != "first->second" || ps[1].Name() != "second->first" {
+		t.Fatalf("unexpected pairs %v", ps)
+	}
+	if ps[0].Count != 2 || ps[0].Covered != 14 {
+		t.Errorf("first->second: actual and expected do not match. Actual: %v, %v. Expected: 2, 14.", ps[0].Count, ps[0].Covered)
+	}
+	if ps[1].Count != 0 || ps[1].Covered != 0 {
+		t.Errorf("second->first: actual and expected do not match. Actual: %v, %v. Expected: 0, 0.", ps[1].Count, ps[1].Covered)
+	}
+
+	if ps := GetPairStats(Beds{beds[0], beds[1], beds[0]}, MakeBed("genome"), []int{1}, OverlapOpts{}); len(ps) != 2 || ps[0].Query != "second" || ps[1].Query != "second" {
+		t.Errorf("only the beds to permute should be queries: %v", ps)
+	}
+}
+
+func TestPairPermutations(t *testing.T) {
+	// a is always inside b, and c is unrelated to both
+	var aspans, bspans, cspans []Bspan
+	for i := 0; i < 10; i++ {
+		aspans = append(aspans, MakeBspan("one", i * 1000 + 40, i * 1000 + 50))
+		bspans = append(bspans, MakeBspan("one", i * 1000, i * 1000 + 100))
+		cspans = append(cspans, MakeBspan("one", i * 1000 + 500, i * 1000 + 510))
+	}
+	beds := Beds{toBed("a", aspans), toBed("b", bspans), toBed("c", cspans)}
+	genome := toBed("genome", []Bspan{MakeBspan("one", 0, 10000)})
+
+	ps := PairPermutations(beds, genome, 50, rand.New(rand.NewSource(6)), []int{0}, OverlapOpts{})
+	if len(ps) != 2 || ps[0].Name() != "a->b" || ps[1].Name() != "a->c" {
+		t.Fatalf("unexpected pairs %v", ps)
+	}
+	if ps[0].Count != 10 || ps[0].CountProb > 0.05 || ps[0].CountFold() <= 1 {
+		t.Errorf("a->b is not enriched: %v", ps[0])
+	}
+	if ps[1].Count != 0 || ps[1].CountProb < 0.05 {
+		t.Errorf("a->c is enriched: %v", ps[1])
+	}
+
+	// each iteration permutes a once, and compares it with the fixed b and c
+	randgen := rand.New(rand.NewSource(6))
+	for i := 0; i < 50; i++ {
+		a := PermuteBeds(Beds{beds[0]}, genome, randgen, nil)[0]
+		for j, ref := range beds[1:] {
+			if count := len(AllBedSpans(GetOverlap(Beds{a, ref}).Bed)); count != ps[j].NullCount[i] {
+				t.Fatalf("permutation %v of %v: actual and expected do not match. Actual: %v. Expected: %v.", i, ps[j].Name(), ps[j].NullCount[i], count)
+			}
+		}
+	}
+}
+
+func TestMergePairs(t *testing.T) {
+	beds, genome := checkpointInputs()
+	shard := func(seed, iterations int) Comparison {
+		c := Comparison{Overlaps: GetOverlaps(beds, -1)}
+		c.Meta = RunMeta{Iterations: iterations, Rseed: seed, OverlapOpts: OverlapOpts{MaxComps: -1}, Pairwise: true}
+		c.Meta.InputHash = InputHash(beds, genome, c.Meta)
+		c.Pairs = PairPermutations(beds, genome, iterations, rand.New(rand.NewSource(int64(seed))), nil, c.Meta.OverlapOpts)
+		return c
+	}
+	a, b := shard(1, 5), shard(2, 7)
+	merged, err := MergeComparisons(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range merged.Pairs {
+		expected := append(append([]int{}, a.Pairs[i].NullCount...), b.Pairs[i].NullCount...)
+		if !sameInts(p.NullCount, expected) {
+			t.Errorf("merged counts %v, expected %v", p.NullCount, expected)
+		}
+		if prob := probAbove(p.Count, expected); p.CountProb != prob {
+			t.Errorf("merged prob %v, expected %v", p.CountProb, prob)
+		}
+	}
+}
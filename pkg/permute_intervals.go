@@ -14,6 +14,7 @@ import (
 	"io"
 	"bufio"
 	"os"
+	"regexp"
 	"github.com/jgbaldwinbrown/go-intervals/intervalset"
 )
 
@@ -30,6 +31,36 @@ type Comparison struct {
 	Overlaps Overlaps
 	IterCounts OverlapCounts
 	Probs Probs
+	// Only with RunMeta.Distance
+	Distances DistanceStats
+	// Only with RunMeta.Pairwise, which leaves Overlaps, Permutations,
+	// IterCounts and Probs empty
+	Pairs PairStats
+	Inputs Manifest
+	Meta RunMeta
+}
+
+// The settings a Comparison was run with
+type RunMeta struct {
+	GenomePath string
+	GenomeFormat string
+	// The -gn and -gx settings the genome was read with
+	MinGap int
+	ChromExclude string
+	Iterations int
+	Rseed int
+	OverlapOpts
+	// Whether nearest-neighbour distances were tested too
+	Distance bool
+	// Whether each bed in ToPermute was tested against each other bed by
+	// permuting it alone, instead of testing every subset; see PairStats
+	Pairwise bool
+	// Resolved from the flags or manifest; empty means all beds were permuted
+	ToPermute []int
+	// Identifies the beds, genome and settings; see InputHash
+	InputHash string
+	// The seeds of the shards combined by MergeComparisons
+	MergedSeeds []int
 }
 
 // A span as used by a bed file, with a chromosome and a region
@@ -57,13 +88,32 @@ func Covered(spans []Bspan) (out int) {
 
 type Flags struct {
 	BedPaths string
+	// Beds named on the command line, as path or label=path
+	BedArgs []string
 	GenomeBedPath string
 	Iterations int
 	Rseed int
 	Verbose bool
-	MaxComps int
+	LogLevel string
+	OverlapOpts
+	Distance bool
+	Pairwise bool
+	// A file of subsets to compare, added to OverlapOpts.Subsets
+	SubsetsPath string
+	// Label of a bed to compare each other bed with, adding to
+	// OverlapOpts.Subsets
+	Reference string
 	ToPermute []int
 	CountsPrint bool
+	OutFormat string
+	OutPrefix string
+	OutArrow bool
+	ReportPath string
+	ShardPath string
+	Checkpoint CheckpointOpts
+	GenomeFormat string
+	ChromExclude string
+	MinGap int
 }
 
 type Bed struct {
@@ -163,33 +213,26 @@ func GetBed(r io.Reader, name string) (b Bed, err error) {
 	return
 }
 
-// put all of the lines in a path into a []string
+// Get the paths of all beds listed in the manifest at bedpaths_path
 func GetBedpaths(bedpaths_path string) (paths []string, err error) {
-	r, err := os.Open(bedpaths_path)
+	m, err := GetManifest(bedpaths_path)
 	if err != nil { return }
-	s := bufio.NewScanner(r)
-	for s.Scan() {
-		paths = append(paths, s.Text())
-	}
-	return
+	return m.Paths(), nil
 }
 
-// for each line in bedpaths_path, parse a Bed and add it to beds
-func GetBeds(bedpaths_path string) (beds Beds, err error) {
-	bedpaths, err := GetBedpaths(bedpaths_path)
+// Open and parse the bed at path
+func GetBedPath(path string, name string) (b Bed, err error) {
+	r, err := OpenPath(path)
 	if err != nil { return }
-	for _, path := range bedpaths {
-		var r *os.File
-		r, err = os.Open(path)
-		if err != nil { return }
-		defer r.Close()
+	defer r.Close()
+	return GetBed(r, name)
+}
 
-		var bed Bed
-		bed, err = GetBed(r, path)
-		if err != nil { return }
-		beds = append(beds, bed)
-	}
-	return
+// for each entry in the manifest at bedpaths_path, parse a Bed and add it to beds
+func GetBeds(bedpaths_path string) (beds Beds, err error) {
+	m, err := GetManifest(bedpaths_path)
+	if err != nil { return }
+	return m.Beds()
 }
 
 // Just a wrapper for dest.AddBspans
@@ -286,18 +329,10 @@ func BedPerm(beds Beds, perm *big.Int) (bedperm Beds) {
 	return
 }
 
-// Get all possible permutations of bed files as overlaps
+// Get all possible permutations of bed files as overlaps, in the order of
+// their binary masks; see SubsetOverlaps
 func GetAllOverlaps(beds Beds) (overlaps Overlaps) {
-	perm := big.NewInt(0)
-	maxperm := big.NewInt(0)
-	one := big.NewInt(1)
-	BigPow(maxperm, big.NewInt(int64(len(beds))))
-
-	for ; perm.Cmp(maxperm) < 0 ; perm.Add(perm, one) {
-		to_overlap := BedPerm(beds, perm)
-		overlaps = append(overlaps, GetOverlap(to_overlap))
-	}
-	return
+	return SubsetOverlaps(beds, -1)
 }
 
 // Add up all of the "1" digits in the binary representation of src, put the result in sum
@@ -314,22 +349,79 @@ func BinSum(sum *big.Int, src *big.Int) {
 
 // Get all permutations of beds, but only overlapping up to maxComps beds at a time
 func GetLimitedOverlaps(beds Beds, maxComps int) (overlaps Overlaps) {
-	perm := big.NewInt(0)
-	maxperm := big.NewInt(0)
-	one := big.NewInt(1)
-	BigPow(maxperm, big.NewInt(int64(len(beds))))
-	binsum := big.NewInt(0)
-
-	for ; perm.Cmp(maxperm) < 0 ; perm.Add(perm, one) {
-		BinSum(binsum, perm)
-		if int(binsum.Int64()) <= maxComps {
-			to_overlap := BedPerm(beds, perm)
-			overlaps = append(overlaps, GetOverlap(to_overlap))
+	return SubsetOverlaps(beds, maxComps)
+}
+
+// The overlaps of every subset of at most maxComps beds (of any size, if
+// maxComps is negative), in the order of their binary masks, where bit j of
+// mask i says whether subset i has beds[j]: the empty subset, beds[0],
+// beds[1], beds[0]:beds[1], beds[2], and so on. As in GetOverlap, the
+// single-bed subsets are empty.
+//
+// Only the subsets that are wanted are generated: those with beds[k] as their
+// last bed are the earlier ones with room for another bed, with beds[k]
+// added. The intersection of each is that of the earlier subset intersected
+// with beds[k], so every intersection is found with a single pass over two
+// beds.
+func SubsetOverlaps(beds Beds, maxComps int) (overlaps Overlaps) {
+	// the intersections of the subsets in overlaps, with the beds themselves
+	// for the single-bed subsets
+	inters := []Bed{MakeBed("")}
+	sizes := []int{0}
+	overlaps = Overlaps{GetOverlap(nil)}
+	for _, bed := range beds {
+		n := len(overlaps)
+		for i := 0; i < n; i++ {
+			if maxComps >= 0 && sizes[i] >= maxComps {
+				continue
+			}
+			components := append(append([]string{}, overlaps[i].Components...), bed.Name)
+			name := strings.Join(components, ":")
+			if sizes[i] == 0 {
+				inters = append(inters, bed)
+				overlaps = append(overlaps, Overlap{MakeBed(name), components})
+			} else {
+				inter := intersectTwo(name, inters[i], bed)
+				inters = append(inters, inter)
+				overlaps = append(overlaps, Overlap{inter, components})
+			}
+			sizes = append(sizes, sizes[i] + 1)
 		}
 	}
 	return
 }
 
+// A new bed, named name, covering the bases covered by both a and b, found
+// by walking their sorted spans together
+func intersectTwo(name string, a, b Bed) Bed {
+	out := MakeBed(name)
+	for _, chrom := range a.Chroms {
+		bset, ok := b.Intervals[chrom]
+		if !ok {
+			continue
+		}
+		as, bs := AllBspans(chrom, a.Intervals[chrom]), AllBspans(chrom, bset)
+		var spans []Bspan
+		for i, j := 0, 0; i < len(as) && j < len(bs); {
+			lo, hi := as[i].Min, as[i].Max
+			if bs[j].Min > lo { lo = bs[j].Min }
+			if bs[j].Max < hi { hi = bs[j].Max }
+			if lo < hi {
+				spans = append(spans, MakeBspan(chrom, lo, hi))
+			}
+			if as[i].Max < bs[j].Max {
+				i++
+			} else {
+				j++
+			}
+		}
+		if len(spans) > 0 {
+			out.addSortedBspans(chrom, spans)
+		}
+	}
+	return out
+}
+
 func GetOverlaps(beds Beds, maxComps int) (overlaps Overlaps) {
 	if maxComps < 0 {
 		return GetAllOverlaps(beds)
@@ -350,28 +442,13 @@ func parseIndices(s string) ([]int, error) {
 	return out, nil
 }
 
+// Parse the flags of the original command line, panicking on errors; Main
+// is the command line entry point
 func GetFlags() (f Flags) {
-	flag.BoolVar(&f.Verbose, "v", false, "Print much more information while running")
-	flag.StringVar(&f.BedPaths, "b", "", "File containing paths to all bed files to compare")
-	flag.StringVar(&f.GenomeBedPath, "g", "", "Bed file containing the lengths of all chromosomes")
-	flag.IntVar(&f.Iterations, "i", -1, "Number of permutation iterations to perform")
-	flag.IntVar(&f.Rseed, "r", 0, "Random seed for permutations (default 0)")
-	flag.IntVar(&f.MaxComps, "m", 4, "Maximum number of beds to compare at once")
-	flag.BoolVar(&f.CountsPrint, "c", false, "Output raw overlap counts from each permutation")
-	toPermuteStrp := flag.String("p", "", "comma-separated list of 0-indexed indices of beds to permute (default all)")
-	flag.Parse()
-	if f.BedPaths == "" || f.GenomeBedPath == "" {
-		panic(fmt.Errorf("Missing path"))
-	}
-
-	if *toPermuteStrp != "" {
-		var e error
-		f.ToPermute, e = parseIndices(*toPermuteStrp)
-		if e != nil {
-			panic(e)
-		}
+	f, err := ParseTestFlags(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		panic(err)
 	}
-
 	return
 }
 
@@ -468,28 +545,67 @@ func SpanNumPositions(span Bspan, genome Bed) (positions int) {
 	return
 }
 
-// From a raw number indicating where to put the span, generate a new span at the indexed location in the genome
+// From a raw number indicating where to put the span, generate a new span at the indexed location in the genome.
+// Positions are numbered the same way as in SpanNumPositions, and genome spans need not start at 0.
 func Raw2Bspan(rawpos int, span Bspan, genome Bed) (newspan Bspan) {
 	chrbspans := AllBedSpans(genome)
 	for _, chrspan := range chrbspans {
-		width := chrspan.Width() - span.Width()
-		if rawpos < width {
-			newspan = Bspan{Chrom: chrspan.Chrom, Span: intervalset.Span{Min: rawpos, Max: rawpos + span.Width()}}
+		cw, sw := chrspan.Width(), span.Width()
+		if cw < sw {
+			continue
+		}
+		npos := 1 + cw - sw
+		if rawpos < npos {
+			newspan = MakeBspan(chrspan.Chrom, chrspan.Min + rawpos, chrspan.Min + rawpos + sw)
 			return
 		}
-		rawpos -= width
+		rawpos -= npos
+	}
+	return
+}
+
+// Check that every span of the beds listed in toPermute (or of all beds, if
+// it is empty) fits in some span of the genome, so that PermuteBeds can place
+// it
+func CheckFits(beds Beds, genome Bed, toPermute []int) error {
+	widest := 0
+	for _, span := range AllBedSpans(genome) {
+		if span.Width() > widest {
+			widest = span.Width()
+		}
+	}
+	for _, i := range bedsToPermute(len(beds), toPermute) {
+		for _, span := range AllBedSpans(beds[i]) {
+			if span.Width() > widest {
+				return fmt.Errorf("span %v:%v-%v of %v (%v bp) does not fit in any span of the genome, the widest of which is %v bp", span.Chrom, span.Min, span.Max, beds[i].Name, span.Width(), widest)
+			}
+		}
+	}
+	return nil
+}
+
+// The indices of the beds in toPermute, or of all beds if it is empty, in bed
+// order
+func bedsToPermute(nbeds int, toPermute []int) (out []int) {
+	toperm := make(map[int]struct{}, len(toPermute))
+	for _, i := range toPermute {
+		toperm[i] = struct{}{}
+	}
+	for i := 0; i < nbeds; i++ {
+		if _, ok := toperm[i]; ok || len(toPermute) < 1 {
+			out = append(out, i)
+		}
 	}
 	return
 }
 
-// Move a span to a random location somewhere in the genome
+// Move a span to a random location somewhere in the genome. The span must fit
+// in some span of the genome, which CheckFits checks for whole beds.
 func RandomizeSpan(span Bspan, genome Bed, randgen *rand.Rand) Bspan {
 	npos := SpanNumPositions(span, genome)
 	if npos < 1 {
-		fmt.Fprintln(os.Stderr, "empty newspan")
-		fmt.Fprintln(os.Stderr, span)
-		fmt.Fprintln(os.Stderr, len(genome.Bspans()))
-		fmt.Fprintln(os.Stderr, genome.Bspans())
+		Log().Debugf("genome (%v spans): %v", len(genome.Bspans()), genome.Bspans())
+		panic(fmt.Errorf("span %v (%v bp) does not fit in any span of the genome", span, span.Width()))
 	}
 	rawpos := randgen.Intn(npos)
 	return Raw2Bspan(rawpos, span, genome)
@@ -505,12 +621,25 @@ func RandomlyPlace(span Bspan, dest *Bed, genome Bed, randgen *rand.Rand) {
 
 // Take all beds, then randomly permute all their span positions, then calculate overlaps for the permuted beds
 func Permute(beds Beds, genome Bed, randgen *rand.Rand, maxComps int, toPermute []int) (ovls Overlaps) {
+	return PermuteOpts(beds, genome, randgen, toPermute, OverlapOpts{MaxComps: maxComps})
+}
+
+// Permute, finding the overlaps as opts says
+func PermuteOpts(beds Beds, genome Bed, randgen *rand.Rand, toPermute []int, opts OverlapOpts) (ovls Overlaps) {
+	ovls = GetWindowOverlaps(PermuteBeds(beds, genome, randgen, toPermute), genome, opts)
+	// fmt.Println("ovls:")
+	// fmt.Println(ovls)
+	return
+}
+
+// Randomly place every span of the beds listed in toPermute (or of all beds,
+// if it is empty) in the genome, leaving the others as they are
+func PermuteBeds(beds Beds, genome Bed, randgen *rand.Rand, toPermute []int) (new_beds Beds) {
 	toperm := make(map[int]struct{}, len(toPermute))
 	for _, i := range toPermute {
 		toperm[i] = struct{}{}
 	}
 
-	var new_beds Beds
 	for i, bed := range beds {
 		_, ok := toperm[i]
 		if ok || len(toPermute) < 1 {
@@ -524,16 +653,18 @@ func Permute(beds Beds, genome Bed, randgen *rand.Rand, maxComps int, toPermute
 			new_beds = append(new_beds, bed)
 		}
 	}
-	ovls = GetOverlaps(new_beds, maxComps)
-	// fmt.Println("ovls:")
-	// fmt.Println(ovls)
 	return
 }
 
 // run Permute as many times as specified in iterations
 func Permutations(beds Beds, genome Bed, iterations int, randgen *rand.Rand, maxComps int, toPermute []int) (osets OverlapSets) {
+	return PermutationsOpts(beds, genome, iterations, randgen, toPermute, OverlapOpts{MaxComps: maxComps})
+}
+
+// run PermuteOpts as many times as specified in iterations
+func PermutationsOpts(beds Beds, genome Bed, iterations int, randgen *rand.Rand, toPermute []int, opts OverlapOpts) (osets OverlapSets) {
 	for i:=0; i<iterations; i++ {
-		osets = append(osets, Permute(beds, genome, randgen, maxComps, toPermute))
+		osets = append(osets, PermuteOpts(beds, genome, randgen, toPermute, opts))
 	}
 	return
 }
@@ -542,29 +673,33 @@ func CountPermutations(permutations OverlapSets) (counts OverlapCounts) {
 	// fmt.Println("permutations:")
 	// fmt.Println(permutations)
 	for _, overlaps := range permutations {
-		for i, overlap := range overlaps {
-			if len(counts) <= i {
-				counts = append(counts, OverlapCount{Name: overlap.Name, Components: overlap.Components})
-			}
-			spans := AllBedSpans(overlap.Bed)
-			counts[i].Count = append(counts[i].Count, len(spans))
-			counts[i].Covered = append(counts[i].Covered, Covered(spans))
-		}
+		counts = AddPermutationCounts(counts, overlaps)
 	}
 	return
 }
 
+// Add the counts from one permutation to counts
+func AddPermutationCounts(counts OverlapCounts, overlaps Overlaps) OverlapCounts {
+	for i, overlap := range overlaps {
+		if len(counts) <= i {
+			counts = append(counts, OverlapCount{Name: overlap.Name, Components: overlap.Components})
+		}
+		spans := AllBedSpans(overlap.Bed)
+		counts[i].Count = append(counts[i].Count, len(spans))
+		counts[i].Covered = append(counts[i].Covered, Covered(spans))
+	}
+	return counts
+}
+
 func FprintPermCounts(w io.Writer, counts OverlapCounts) {
 	for _, count := range counts {
 		fmt.Fprintf(w, "%v\t%v\t%v\n", count.Name, count.Count, count.Covered)
 	}
 }
 
+// Read a genome in any supported format; see GetGenomeFormat
 func GetGenome(path string) (b Bed, e error) {
-	var r *os.File
-	r, e = os.Open(path)
-	if e != nil { return }
-	return GetBed(r, "genome")
+	return GetGenomeFormat(path, GenomeAuto, 0)
 }
 
 func pcount(val int, dist []int) int {
@@ -620,44 +755,211 @@ func FprintIterCounts(w io.Writer, os OverlapCounts) {
 	}
 }
 
+// Read the genome named in the flags, leaving out the chromosomes matching
+// flags.ChromExclude. The genome is only needed to permute, so it is empty if
+// there is no genome path and no iterations.
+func GetFlagsGenome(flags Flags) (genome Bed, err error) {
+	if flags.GenomeFormat == "" {
+		flags.GenomeFormat = GenomeAuto
+	}
+	genome = MakeBed("genome")
+	if flags.GenomeBedPath != "" || flags.Iterations > 0 {
+		genome, err = GetGenomeFormat(flags.GenomeBedPath, flags.GenomeFormat, flags.MinGap)
+		if err != nil { return }
+	}
+	if flags.ChromExclude != "" {
+		var exclude *regexp.Regexp
+		exclude, err = regexp.Compile(flags.ChromExclude)
+		if err != nil { return }
+		genome = FilterChroms(genome, exclude)
+	}
+	return
+}
+
+// Get the beds in the flags and which of them to permute: flags.ToPermute if
+// set, and otherwise as the manifest says
+func GetFlagsBeds(flags Flags) (inputs Manifest, beds Beds, toPermute []int, err error) {
+	inputs, err = GetInputs(flags)
+	if err != nil { return }
+	beds, err = inputs.Beds()
+	if err != nil { return }
+	toPermute = flags.ToPermute
+	if len(toPermute) < 1 {
+		toPermute = inputs.ToPermute()
+	}
+	return
+}
+
+// The subsets chosen by flags.Subsets, the file at flags.SubsetsPath and
+// flags.Reference, resolved with ResolveSubsets; empty if none are chosen
+func GetFlagsSubsets(flags Flags, beds Beds) (subsets [][]string, err error) {
+	subsets = append(subsets, flags.Subsets...)
+	if flags.SubsetsPath != "" {
+		var fromFile [][]string
+		fromFile, err = GetSubsets(flags.SubsetsPath)
+		if err != nil { return }
+		subsets = append(subsets, fromFile...)
+	}
+	if flags.Reference != "" {
+		found := false
+		for _, b := range beds {
+			if b.Name == flags.Reference {
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no bed is labelled %q", flags.Reference)
+		}
+		subsets = append(subsets, ReferenceSubsets(beds, flags.Reference)...)
+	}
+	return ResolveSubsets(beds, subsets)
+}
+
 func FullCompare(flags Flags) (c Comparison, err error) {
-	genome, err := GetGenome(flags.GenomeBedPath)
+	if flags.GenomeFormat == "" {
+		flags.GenomeFormat = GenomeAuto
+	}
+	genome, err := GetFlagsGenome(flags)
 	if err != nil { return }
-	beds, err := GetBeds(flags.BedPaths)
+	var beds Beds
+	var toPermute []int
+	c.Inputs, beds, toPermute, err = GetFlagsBeds(flags)
 	if err != nil { return }
+	flags.Subsets, err = GetFlagsSubsets(flags, beds)
+	if err != nil { return }
+	if flags.Iterations > 0 {
+		if err = CheckFits(beds, genome, toPermute); err != nil { return }
+	}
+
+	c.Meta = RunMeta {
+		GenomePath: flags.GenomeBedPath,
+		GenomeFormat: flags.GenomeFormat,
+		MinGap: flags.MinGap,
+		ChromExclude: flags.ChromExclude,
+		Iterations: flags.Iterations,
+		Rseed: flags.Rseed,
+		OverlapOpts: flags.OverlapOpts,
+		Distance: flags.Distance,
+		Pairwise: flags.Pairwise,
+		ToPermute: toPermute,
+	}
+	c.Meta.InputHash = InputHash(beds, genome, c.Meta)
 
+	log := Log()
 	if flags.Verbose {
-		fmt.Println("inputs:")
+		log = log.WithLevel(LogDebug)
+	}
+	log.Infof("%v beds, genome of %v spans, permuting %v", len(beds), len(AllBedSpans(genome)), toPermute)
+	if log.Enabled(LogDebug) {
 		for _, bed := range beds {
-			fmt.Println(bed.Name)
-			bspans := AllBedSpans(bed)
-			WriteBspans(os.Stdout, bspans...)
+			var b strings.Builder
+			WriteBspans(&b, AllBedSpans(bed)...)
+			log.Debugf("input %v:\n%v", bed.Name, b.String())
 		}
 	}
 
-	c.Overlaps = GetOverlaps(beds, flags.MaxComps)
-	if flags.Iterations > 0 {
+	if flags.Pairwise {
+		if flags.Distance || flags.Exclusive || len(flags.Subsets) > 0 || (flags.Iterations > 0 && flags.Checkpoint.Path != "") {
+			return c, fmt.Errorf("pairwise tests cannot be combined with distances, exclusive overlaps, chosen subsets or checkpoints")
+		}
+		// the subsets are not tested, so they are not intersected either
+		log.Infof("running %v pairwise permutations with seed %v", flags.Iterations, flags.Rseed)
+		randgen := rand.New(rand.NewSource(int64(flags.Rseed)))
+		c.Pairs = PairPermutations(beds, genome, flags.Iterations, randgen, toPermute, flags.OverlapOpts)
+		return
+	}
+
+	c.Overlaps = GetWindowOverlaps(beds, genome, flags.OverlapOpts)
+	if flags.Distance {
+		c.Distances = GetDistanceStats(beds)
+	}
+	if flags.Iterations > 0 && flags.Checkpoint.Path != "" {
+		if flags.Distance {
+			return c, fmt.Errorf("distances cannot be checkpointed")
+		}
+		c.IterCounts, err = CheckpointedCounts(beds, genome, c.Meta, flags.Checkpoint)
+		if err != nil { return }
+		c.Probs = GetProbs(c.Overlaps, c.IterCounts)
+	} else if flags.Iterations > 0 {
+		log.Infof("running %v permutations with seed %v", flags.Iterations, flags.Rseed)
 		randgen := rand.New(rand.NewSource(int64(flags.Rseed)))
-		c.Permutations = Permutations(beds, genome, flags.Iterations, randgen, flags.MaxComps, flags.ToPermute)
+		for i := 0; i < flags.Iterations; i++ {
+			// as PermuteOpts, keeping the permuted beds for the distances
+			permuted := PermuteBeds(beds, genome, randgen, toPermute)
+			c.Permutations = append(c.Permutations, GetWindowOverlaps(permuted, genome, flags.OverlapOpts))
+			if flags.Distance {
+				c.Distances = AddPermutationDistances(c.Distances, permuted)
+			}
+		}
 		c.IterCounts = CountPermutations(c.Permutations)
 		c.Probs = GetProbs(c.Overlaps, c.IterCounts)
+		GetDistanceProbs(c.Distances)
 	}
 	return
 }
 
+// The original permute_intervals command: "permuvals test", or "permuvals
+// merge" if the first argument is merge
 func Full() {
-	flags := GetFlags()
-	w := bufio.NewWriter(os.Stdout)
-	defer w.Flush()
+	args := os.Args[1:]
+	if len(args) < 1 || args[0] != "merge" {
+		args = append([]string{"test"}, args...)
+	}
+	os.Exit(Main(args, os.Stdout, os.Stderr))
+}
+
+// Write comp wherever the output flags say to, with stdout as the default
+func WriteOutputs(stdout io.Writer, flags Flags, comp Comparison) (err error) {
+	if flags.ReportPath != "" {
+		err = writeFile(flags.ReportPath, func(w io.Writer) error { return WriteReport(w, comp) })
+		if err != nil { return }
+	}
+	if flags.ShardPath != "" {
+		return writeFile(flags.ShardPath, func(w io.Writer) error { return FprintComparisonJSON(w, comp) })
+	}
+	if flags.OutPrefix != "" {
+		return WriteComparisonFiles(flags.OutPrefix, comp, flags.OutArrow)
+	}
 
-	comp, err := FullCompare(flags)
-	if err != nil { panic(err) }
-	if flags.CountsPrint {
+	w := bufio.NewWriter(stdout)
+	defer func() {
+		if ferr := w.Flush(); err == nil {
+			err = ferr
+		}
+	}()
+	switch flags.OutFormat {
+	case OutJSON:
+		err = FprintComparisonJSON(w, comp)
+	case OutJSONL:
+		err = FprintComparisonJSONL(w, comp)
+	case OutTSV, "":
+		FprintComparisonTSV(w, comp, flags.CountsPrint)
+	default:
+		err = fmt.Errorf("unknown output format %q", flags.OutFormat)
+	}
+	return
+}
+
+// The original text output: the observed overlaps as a bed, followed by the
+// probabilities, or just the permutation counts if countsPrint is set. Any
+// pairwise tests (see FprintPairs) and distances (see FprintDistances) follow,
+// each under its header line, so that they can be told apart from the
+// probabilities.
+func FprintComparisonTSV(w io.Writer, comp Comparison, countsPrint bool) {
+	if countsPrint {
 		FprintIterCounts(w, comp.IterCounts)
 		return
 	}
 	FprintOvlsBed(w, comp.Overlaps)
-	if flags.Iterations > 0 {
+	if comp.Meta.Iterations > 0 {
 		FprintProbs(w, comp.Probs)
 	}
+	if len(comp.Pairs) > 0 {
+		fmt.Fprintln(w, PairsHeader)
+		FprintPairs(w, comp.Pairs)
+	}
+	if len(comp.Distances) > 0 {
+		fmt.Fprintln(w, DistancesHeader)
+		FprintDistances(w, comp.Distances)
+	}
 }
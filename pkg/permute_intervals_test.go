@@ -2,6 +2,9 @@ package permuvals
 
 import (
 	"fmt"
+	"math/big"
+	"math/bits"
+	"math/rand"
 	"testing"
 	"github.com/jgbaldwinbrown/go-intervals/intervalset"
 	"strings"
@@ -37,6 +40,51 @@ func TestGetOverlaps(t *testing.T) {
 	}
 }
 
+// The overlaps of the subsets with at most maxComps beds, one binary mask at a
+// time, as GetOverlaps used to find them
+func maskOverlaps(beds Beds, maxComps int) (overlaps Overlaps) {
+	for mask := 0; mask < 1 << len(beds); mask++ {
+		if maxComps >= 0 && bits.OnesCount(uint(mask)) > maxComps {
+			continue
+		}
+		overlaps = append(overlaps, GetOverlap(BedPerm(beds, big.NewInt(int64(mask)))))
+	}
+	return
+}
+
+func TestSubsetOverlaps(t *testing.T) {
+	randgen := rand.New(rand.NewSource(7))
+	genome := toBed("genome", genomeBspans())
+	var beds Beds
+	for i := 0; i < 6; i++ {
+		var spans []Bspan
+		for j := 0; j < 20; j++ {
+			spans = append(spans, RandomizeSpan(MakeBspan("one", 0, 5 + randgen.Intn(30)), genome, randgen))
+		}
+		beds = append(beds, toBed(fmt.Sprint("bed", i), spans))
+	}
+	for _, maxComps := range []int{-1, 0, 1, 2, 4, 6} {
+		actual, expected := GetOverlaps(beds, maxComps), maskOverlaps(beds, maxComps)
+		if len(actual) != len(expected) {
+			t.Fatalf("-m %v: %v subsets, expected %v", maxComps, len(actual), len(expected))
+		}
+		for i := range actual {
+			if actual[i].Name != expected[i].Name || !sameStrings(actual[i].Components, expected[i].Components) {
+				t.Fatalf("-m %v: subset %v is %v, expected %v", maxComps, i, actual[i].Name, expected[i].Name)
+			}
+			checkBspans(t, AllBedSpans(actual[i].Bed), AllBedSpans(expected[i].Bed))
+		}
+	}
+	// subsets of at most 2 of 20 beds: 1 + 20 + 190
+	var many Beds
+	for i := 0; i < 20; i++ {
+		many = append(many, beds[i % len(beds)])
+	}
+	if n := len(GetOverlaps(many, 2)); n != 211 {
+		t.Errorf("%v subsets of at most 2 of 20 beds, expected 211", n)
+	}
+}
+
 
 func TestGetProbs(t *testing.T) {
 	bactual := MakeBed("actual")
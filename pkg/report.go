@@ -0,0 +1,273 @@
+package permuvals
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// NaN if x is empty
+func meanInts(x []int) float64 {
+	if len(x) < 1 {
+		return math.NaN()
+	}
+	sum := 0
+	for _, v := range x {
+		sum += v
+	}
+	return float64(sum) / float64(len(x))
+}
+
+// Observed value over the mean of the null distribution; NaN if the null is empty
+func FoldEnrichment(observed int, null []int) float64 {
+	return float64(observed) / meanInts(null)
+}
+
+// One row of the report: the observed values and null distribution of one subset
+type ReportRow struct {
+	Name string
+	Components []string
+	Count int
+	Covered int
+	Null OverlapCount
+	Prob Prob
+}
+
+func (r ReportRow) CountMean() float64 { return meanInts(r.Null.Count) }
+func (r ReportRow) CoveredMean() float64 { return meanInts(r.Null.Covered) }
+func (r ReportRow) CountFold() float64 { return FoldEnrichment(r.Count, r.Null.Count) }
+func (r ReportRow) CoveredFold() float64 { return FoldEnrichment(r.Covered, r.Null.Covered) }
+
+// The subsets of c with at least one component, in the order of c.Overlaps
+func ReportRows(c Comparison) []ReportRow {
+	counts := make(map[string]OverlapCount, len(c.IterCounts))
+	for _, count := range c.IterCounts {
+		counts[count.Name] = count
+	}
+	probs := make(map[string]Prob, len(c.Probs))
+	for _, prob := range c.Probs {
+		probs[prob.Name] = prob
+	}
+	var rows []ReportRow
+	for _, ovl := range c.Overlaps {
+		if len(ovl.Components) < 1 {
+			continue
+		}
+		bspans := AllBedSpans(ovl.Bed)
+		nan := math.NaN()
+		prob, ok := probs[ovl.Name]
+		if !ok {
+			prob = Prob{ovl.Name, nan, nan}
+		}
+		rows = append(rows, ReportRow {
+			Name: ovl.Name,
+			Components: ovl.Components,
+			Count: len(bspans),
+			Covered: Covered(bspans),
+			Null: counts[ovl.Name],
+			Prob: prob,
+		})
+	}
+	return rows
+}
+
+// Sizes used by all of the SVG plots
+const (
+	svgWidth = 360
+	svgHeight = 200
+	svgMargin = 40
+	svgMaxBins = 30
+)
+
+func svgText(x, y float64, anchor, text string) string {
+	return fmt.Sprintf(`<text x="%.1f" y="%.1f" text-anchor="%v" font-size="11">%v</text>`, x, y, anchor, template.HTMLEscapeString(text))
+}
+
+// An SVG histogram of null, with a red line at observed
+func HistogramSVG(title string, null []int, observed int) template.HTML {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v">`, svgWidth, svgHeight)
+	b.WriteString(svgText(svgWidth / 2, 14, "middle", title))
+
+	lo, hi := observed, observed
+	for _, x := range null {
+		if x < lo { lo = x }
+		if x > hi { hi = x }
+	}
+	nbins := hi - lo + 1
+	if nbins > svgMaxBins {
+		nbins = svgMaxBins
+	}
+	binWidth := float64(hi - lo + 1) / float64(nbins)
+	bins := make([]int, nbins)
+	maxBin := 1
+	for _, x := range null {
+		i := int(float64(x - lo) / binWidth)
+		if i >= nbins { i = nbins - 1 }
+		bins[i]++
+		if bins[i] > maxBin { maxBin = bins[i] }
+	}
+
+	plotW, plotH := float64(svgWidth - 2 * svgMargin), float64(svgHeight - 2 * svgMargin)
+	xpos := func(x float64) float64 { return svgMargin + plotW * (x - float64(lo)) / float64(hi - lo + 1) }
+	barW := plotW / float64(nbins)
+	for i, n := range bins {
+		h := plotH * float64(n) / float64(maxBin)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#8aa9c8" stroke="white"/>`,
+			svgMargin + barW * float64(i), svgMargin + plotH - h, barW, h)
+	}
+	fmt.Fprintf(&b, `<line x1="%v" y1="%v" x2="%v" y2="%v" stroke="black"/>`, svgMargin, svgMargin + plotH, svgMargin + plotW, svgMargin + plotH)
+	ox := xpos(float64(observed) + 0.5)
+	fmt.Fprintf(&b, `<line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="#c0392b" stroke-width="2"/>`, ox, svgMargin - 6, ox, svgMargin + plotH)
+	b.WriteString(svgText(ox, svgMargin - 9, "middle", fmt.Sprintf("observed %v", observed)))
+	b.WriteString(svgText(svgMargin, svgMargin + plotH + 14, "start", fmt.Sprint(lo)))
+	b.WriteString(svgText(svgMargin + plotW, svgMargin + plotH + 14, "end", fmt.Sprint(hi)))
+	b.WriteString(svgText(svgMargin - 4, svgMargin + 8, "end", fmt.Sprint(maxBin)))
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+// An UpSet-style plot: one bar per subset showing its observed count, above a
+// matrix of dots marking which inputs make up the subset
+func UpsetSVG(rows []ReportRow, inputs []string) template.HTML {
+	sorted := append([]ReportRow{}, rows...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Count > sorted[j].Count })
+
+	const colW, rowH, labelW, barH = 24.0, 18.0, 120.0, 160.0
+	width := labelW + colW * float64(len(sorted)) + svgMargin
+	height := svgMargin + barH + rowH * float64(len(inputs)) + svgMargin
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f">`, width, height)
+
+	maxCount := 1
+	for _, r := range sorted {
+		if r.Count > maxCount { maxCount = r.Count }
+	}
+	b.WriteString(svgText(labelW - 4, svgMargin + 8, "end", fmt.Sprint(maxCount)))
+	b.WriteString(svgText(labelW - 4, svgMargin + barH, "end", "0"))
+	rowOf := make(map[string]int, len(inputs))
+	for i, in := range inputs {
+		rowOf[in] = i
+		b.WriteString(svgText(labelW - 8, svgMargin + barH + rowH * (float64(i) + 0.7), "end", in))
+	}
+
+	for j, r := range sorted {
+		x := labelW + colW * float64(j)
+		h := barH * float64(r.Count) / float64(maxCount)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#34495e"><title>%v: %v</title></rect>`,
+			x + 3, svgMargin + barH - h, colW - 6, h, template.HTMLEscapeString(r.Name), r.Count)
+		members := map[int]bool{}
+		lo, hi := len(inputs), -1
+		for _, comp := range r.Components {
+			if i, ok := rowOf[comp]; ok {
+				members[i] = true
+				if i < lo { lo = i }
+				if i > hi { hi = i }
+			}
+		}
+		cx := x + colW / 2
+		cy := func(i int) float64 { return svgMargin + barH + rowH * (float64(i) + 0.5) }
+		if hi > lo {
+			fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#34495e" stroke-width="2"/>`, cx, cy(lo), cx, cy(hi))
+		}
+		for i := range inputs {
+			fill := "#dddddd"
+			if members[i] {
+				fill = "#34495e"
+			}
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="5" fill="%v"/>`, cx, cy(i), fill)
+		}
+	}
+	b.WriteString(`</svg>`)
+	return template.HTML(b.String())
+}
+
+type reportData struct {
+	Meta RunMeta
+	Inputs Manifest
+	Rows []ReportRow
+	Upset template.HTML
+	// Empty if there are no tests of pairs of beds
+	Heatmap template.HTML
+	Histograms []reportHistograms
+}
+
+type reportHistograms struct {
+	Name string
+	Count template.HTML
+	Covered template.HTML
+}
+
+var reportFuncs = template.FuncMap {
+	"num": func(x float64) string {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return "NA"
+		}
+		return fmt.Sprintf("%.3g", x)
+	},
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>permuvals report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.6em; border-bottom: 1px solid #ccc; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+.hists { display: flex; flex-wrap: wrap; gap: 1em; }
+</style>
+</head>
+<body>
+<h1>Permutation test of interval overlaps</h1>
+<p>Genome {{.Meta.GenomePath}}; {{.Meta.Iterations}} permutations with seed {{.Meta.Rseed}}.</p>
+<h2>Inputs</h2>
+<table>
+<tr><th>label</th><th>group</th><th>path</th></tr>
+{{range .Inputs}}<tr><td>{{.Label}}</td><td>{{.Group}}</td><td>{{.Path}}</td></tr>
+{{end}}</table>
+<h2>Probabilities</h2>
+<table>
+<tr><th>subset</th><th>count</th><th>null mean</th><th>fold</th><th>p</th><th>covered bp</th><th>null mean</th><th>fold</th><th>p</th></tr>
+{{range .Rows}}<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{num .CountMean}}</td><td>{{num .CountFold}}</td><td>{{num .Prob.CountProb}}</td><td>{{.Covered}}</td><td>{{num .CoveredMean}}</td><td>{{num .CoveredFold}}</td><td>{{num .Prob.CoveredProb}}</td></tr>
+{{end}}</table>
+<h2>Subset sizes</h2>
+{{.Upset}}
+{{if .Heatmap}}<h2>Pairwise enrichment</h2>
+{{.Heatmap}}
+{{end}}<h2>Null distributions</h2>
+{{range .Histograms}}<h3>{{.Name}}</h3>
+<div class="hists">{{.Count}}{{.Covered}}</div>
+{{end}}</body>
+</html>
+`))
+
+// Write a self-contained HTML report of c, with a table of probabilities and
+// fold enrichments, an UpSet-style plot of the observed subset sizes, a
+// heatmap of the pairwise enrichments (see PairMatrix), and a histogram of
+// each subset's null distribution
+func WriteReport(w io.Writer, c Comparison) error {
+	rows := ReportRows(c)
+	inputs := comparisonLabels(c)
+
+	data := reportData{Meta: c.Meta, Inputs: c.Inputs, Rows: rows, Upset: UpsetSVG(rows, inputs)}
+	if m := NewPairMatrix(c); m.tested() {
+		data.Heatmap = HeatmapSVG("coverage fold enrichment", m)
+	}
+	for _, r := range rows {
+		if len(r.Null.Count) < 1 {
+			continue
+		}
+		data.Histograms = append(data.Histograms, reportHistograms {
+			Name: r.Name,
+			Count: HistogramSVG("count", r.Null.Count, r.Count),
+			Covered: HistogramSVG("covered bp", r.Null.Covered, r.Covered),
+		})
+	}
+	return reportTemplate.Execute(w, data)
+}
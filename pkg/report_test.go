@@ -0,0 +1,55 @@
+package permuvals
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestFoldEnrichment(t *testing.T) {
+	if f := FoldEnrichment(6, []int{2, 4}); f != 2 {
+		t.Errorf("fold %v != 2", f)
+	}
+	if f := FoldEnrichment(6, nil); !math.IsNaN(f) {
+		t.Errorf("fold of empty null %v is not NaN", f)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	c := testComparison()
+	var b strings.Builder
+	if err := WriteReport(&b, c); err != nil {
+		t.Fatal(err)
+	}
+	html := b.String()
+	for _, want := range []string{"first:second", "<svg", "observed 3", "a.bed", "<td>chip</td>"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+	if strings.Contains(html, "http://") && strings.Count(html, "http://") != strings.Count(html, `xmlns="http://www.w3.org/2000/svg"`) {
+		t.Errorf("report refers to external resources")
+	}
+}
+
+// Check that svg parses as XML
+func checkWellFormedSVG(t *testing.T, svg string) {
+	t.Helper()
+	d := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Errorf("bad svg: %v\n%v", err, svg)
+			return
+		}
+	}
+}
+
+func TestHistogramSVGWellFormed(t *testing.T) {
+	checkWellFormedSVG(t, string(HistogramSVG("a<b", []int{1, 2, 2, 3, 100}, 50)))
+}
@@ -0,0 +1,116 @@
+package permuvals
+
+// A new bed covering the bases covered by every one of beds, named after the
+// first. Unlike GetOverlap, a single bed gives a copy of itself.
+func IntersectBeds(beds ...Bed) Bed {
+	if len(beds) < 1 {
+		return MakeBed("")
+	}
+	out := MakeBed(beds[0].Name)
+	AddBed(&out, beds[0])
+	for _, b := range beds[1:] {
+		out.IntersectBed(b)
+	}
+	return out
+}
+
+// A new bed covering the bases covered by any of beds, with overlapping spans
+// merged
+func UnionBeds(name string, beds ...Bed) Bed {
+	out := MakeBed(name)
+	for _, b := range beds {
+		AddBed(&out, b)
+	}
+	return out
+}
+
+// A new bed covering the bases of b not covered by any of beds
+func SubtractBeds(b Bed, beds ...Bed) Bed {
+	out := MakeBed(b.Name)
+	AddBed(&out, b)
+	for _, src := range beds {
+		out.SubtractBed(src)
+	}
+	return out
+}
+
+// A new bed holding the spans of b that do not overlap any one of beds by at
+// least min
+func SubtractFullBeds(b Bed, min MinOverlap, beds ...Bed) Bed {
+	out := MakeBed(b.Name)
+	AddBed(&out, b)
+	for _, src := range beds {
+		out = out.SubtractFullsBedMin(src, min)
+	}
+	return out
+}
+
+// A new bed covering the bases of genome not covered by b
+func (b Bed) Complement(genome Bed) Bed {
+	out := SubtractBeds(genome, b)
+	out.Name = b.Name
+	return out
+}
+
+// The first and last base of each chromosome in genome
+func ChromExtents(genome Bed) map[string]Bspan {
+	extents := map[string]Bspan{}
+	for _, span := range AllBedSpans(genome) {
+		extent, ok := extents[span.Chrom]
+		if !ok {
+			extents[span.Chrom] = span
+			continue
+		}
+		if span.Min < extent.Min { extent.Min = span.Min }
+		if span.Max > extent.Max { extent.Max = span.Max }
+		extents[span.Chrom] = extent
+	}
+	return extents
+}
+
+// Clip span to its chromosome in extents, or to start at 0 if its chromosome
+// is not there. ok is false if nothing is left.
+func clipSpan(span Bspan, extents map[string]Bspan) (clipped Bspan, ok bool) {
+	lo, hi := 0, span.Max
+	if extent, found := extents[span.Chrom]; found {
+		lo, hi = extent.Min, extent.Max
+	}
+	if span.Min < lo { span.Min = lo }
+	if span.Max > hi { span.Max = hi }
+	return span, span.Min < span.Max
+}
+
+// A new bed with every span of b extended by left bp before its start and
+// right bp after its end, clipped to the chromosome bounds in genome
+func (b Bed) Slop(genome Bed, left, right int) Bed {
+	extents := ChromExtents(genome)
+	out := MakeBed(b.Name)
+	for _, span := range AllBedSpans(b) {
+		span.Min -= left
+		span.Max += right
+		if clipped, ok := clipSpan(span, extents); ok {
+			out.AddBspans(clipped)
+		}
+	}
+	return out
+}
+
+// A new bed holding the left bp before and right bp after each span of b,
+// clipped to the chromosome bounds in genome. The spans of b themselves are
+// not included, but flanks may overlap other spans of b.
+func (b Bed) Flank(genome Bed, left, right int) Bed {
+	extents := ChromExtents(genome)
+	out := MakeBed(b.Name)
+	for _, span := range AllBedSpans(b) {
+		flanks := []Bspan {
+			MakeBspan(span.Chrom, span.Min - left, span.Min),
+			MakeBspan(span.Chrom, span.Max, span.Max + right),
+		}
+		for _, flank := range flanks {
+			if clipped, ok := clipSpan(flank, extents); ok {
+				out.AddBspans(clipped)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package permuvals
+
+import (
+	"testing"
+)
+
+func checkBspans(t *testing.T, actual, expected []Bspan) {
+	if len(actual) != len(expected) {
+		t.Errorf("actual and expected do not match. Actual: %v. Expected: %v.", actual, expected)
+		return
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			t.Errorf("actual and expected do not match. Actual: %v. Expected: %v.", actual, expected)
+			return
+		}
+	}
+}
+
+func TestSetOps(t *testing.T) {
+	in1 := toBed("first", in1Bspans())
+	in2 := toBed("second", in2Bspans())
+
+	checkBspans(t, AllBedSpans(IntersectBeds(in1, in2)), []Bspan {
+		MakeBspan("one", 5, 7),
+		MakeBspan("one", 99, 105),
+		MakeBspan("two", 3, 11),
+	})
+	checkBspans(t, AllBedSpans(UnionBeds("union", in1, in2)), []Bspan {
+		MakeBspan("one", 2, 22),
+		MakeBspan("one", 80, 110),
+		MakeBspan("two", 0, 20),
+		MakeBspan("three", 0, 11),
+	})
+	checkBspans(t, AllBedSpans(SubtractBeds(in1, in2)), []Bspan {
+		MakeBspan("one", 2, 5),
+		MakeBspan("one", 105, 110),
+		MakeBspan("two", 0, 3),
+	})
+	checkBspans(t, AllBedSpans(SubtractBeds(toBed("genome", genomeBspans()), in1, in2)), []Bspan {
+		MakeBspan("one", 0, 2),
+		MakeBspan("one", 22, 80),
+		MakeBspan("one", 110, 200),
+		MakeBspan("two", 20, 300),
+	})
+
+	// the inputs are left alone
+	checkBspans(t, AllBedSpans(in1), in1Bspans())
+	checkBspans(t, AllBedSpans(in2), in2Bspans())
+}
+
+func TestComplementSlopFlank(t *testing.T) {
+	in1 := toBed("first", in1Bspans())
+	// a genome with a gap, and with chromosome two not starting at 0
+	genome := toBed("genome", []Bspan {
+		MakeBspan("one", 0, 50),
+		MakeBspan("one", 60, 200),
+		MakeBspan("two", 5, 300),
+	})
+
+	comp := in1.Complement(genome)
+	if comp.Name != "first" {
+		t.Errorf("complement named %q, expected %q", comp.Name, "first")
+	}
+	checkBspans(t, AllBedSpans(comp), []Bspan {
+		MakeBspan("one", 0, 2),
+		MakeBspan("one", 7, 50),
+		MakeBspan("one", 60, 99),
+		MakeBspan("one", 110, 200),
+		MakeBspan("two", 11, 300),
+	})
+	checkBspans(t, AllBedSpans(in1.Slop(genome, 10, 95)), []Bspan {
+		MakeBspan("one", 0, 200),
+		MakeBspan("two", 5, 106),
+	})
+	checkBspans(t, AllBedSpans(in1.Flank(genome, 3, 4)), []Bspan {
+		MakeBspan("one", 0, 2),
+		MakeBspan("one", 7, 11),
+		MakeBspan("one", 96, 99),
+		MakeBspan("one", 110, 114),
+		MakeBspan("two", 11, 15),
+	})
+	checkBspans(t, AllBedSpans(in1), in1Bspans())
+}
@@ -0,0 +1,148 @@
+package permuvals
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// A hash of everything that must be the same for two runs' null distributions
+// to be combined: the beds, the genome, and the settings other than the seed
+// and number of iterations
+func InputHash(beds Beds, genome Bed, meta RunMeta) string {
+	h := sha256.New()
+	writeBed := func(b Bed) {
+		fmt.Fprintf(h, "bed\t%v\n", b.Name)
+		WriteBspans(h, AllBedSpans(b)...)
+	}
+	for _, b := range beds {
+		writeBed(b)
+	}
+	writeBed(genome)
+	fmt.Fprintf(h, "max_comps\t%v\nto_permute\t%v\n", meta.MaxComps, meta.ToPermute)
+	if !meta.Min.IsZero() {
+		// left out otherwise, so that hashes from before thresholds existed
+		// still match
+		fmt.Fprintf(h, "min_overlap\t%+v\n", meta.Min)
+	}
+	if meta.Window != 0 {
+		fmt.Fprintf(h, "window\t%v\t%v\n", meta.Window, meta.WindowBeds)
+	}
+	if meta.Distance {
+		fmt.Fprintf(h, "distance\n")
+	}
+	if len(meta.Subsets) > 0 {
+		fmt.Fprintf(h, "subsets\t%v\n", meta.Subsets)
+	}
+	if meta.Exclusive {
+		fmt.Fprintf(h, "exclusive\n")
+	}
+	if meta.Pairwise {
+		fmt.Fprintf(h, "pairwise\n")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func ReadComparisonJSONPath(path string) (c Comparison, err error) {
+	r, err := OpenPath(path)
+	if err != nil { return }
+	defer r.Close()
+	return ReadComparisonJSON(r)
+}
+
+// Combine the null distributions of shards: runs with the same inputs and
+// settings but different seeds. The probabilities are recomputed from the
+// combined distribution.
+func MergeComparisons(shards ...Comparison) (c Comparison, err error) {
+	if len(shards) < 1 {
+		return c, fmt.Errorf("no shards to merge")
+	}
+	first := shards[0]
+	c = Comparison{Overlaps: first.Overlaps, Inputs: first.Inputs, Meta: first.Meta}
+	for _, d := range first.Distances {
+		d.NullMean, d.NullMedian, d.NullRelDistMean = nil, nil, nil
+		c.Distances = append(c.Distances, d)
+	}
+	for _, p := range first.Pairs {
+		p.NullCount, p.NullCovered = nil, nil
+		c.Pairs = append(c.Pairs, p)
+	}
+	c.Meta.Iterations = 0
+	c.Meta.MergedSeeds = nil
+
+	seeds := map[int]struct{}{}
+	for i, shard := range shards {
+		if shard.Meta.InputHash == "" || shard.Meta.InputHash != first.Meta.InputHash {
+			return c, fmt.Errorf("shard %v was run with different inputs from shard 0 (input hash %q vs %q)", i, shard.Meta.InputHash, first.Meta.InputHash)
+		}
+		shardSeeds := shard.Meta.MergedSeeds
+		if len(shardSeeds) < 1 {
+			shardSeeds = []int{shard.Meta.Rseed}
+		}
+		for _, seed := range shardSeeds {
+			if _, ok := seeds[seed]; ok {
+				return c, fmt.Errorf("seed %v is used by more than one shard, so their permutations are not independent", seed)
+			}
+			seeds[seed] = struct{}{}
+			c.Meta.MergedSeeds = append(c.Meta.MergedSeeds, seed)
+		}
+		c.Meta.Iterations += shard.Meta.Iterations
+		if len(shard.Distances) != len(c.Distances) {
+			return c, fmt.Errorf("shard %v has %v distances, expected %v", i, len(shard.Distances), len(c.Distances))
+		}
+		for j, d := range shard.Distances {
+			c.Distances[j].NullMean = append(c.Distances[j].NullMean, d.NullMean...)
+			c.Distances[j].NullMedian = append(c.Distances[j].NullMedian, d.NullMedian...)
+			c.Distances[j].NullRelDistMean = append(c.Distances[j].NullRelDistMean, d.NullRelDistMean...)
+		}
+		if len(shard.Pairs) != len(c.Pairs) {
+			return c, fmt.Errorf("shard %v has %v pairs, expected %v", i, len(shard.Pairs), len(c.Pairs))
+		}
+		for j, p := range shard.Pairs {
+			c.Pairs[j].NullCount = append(c.Pairs[j].NullCount, p.NullCount...)
+			c.Pairs[j].NullCovered = append(c.Pairs[j].NullCovered, p.NullCovered...)
+		}
+
+		if i == 0 {
+			for _, count := range shard.IterCounts {
+				count.Count = append([]int{}, count.Count...)
+				count.Covered = append([]int{}, count.Covered...)
+				c.IterCounts = append(c.IterCounts, count)
+			}
+			continue
+		}
+		if len(shard.IterCounts) != len(c.IterCounts) {
+			return c, fmt.Errorf("shard %v has %v subsets, expected %v", i, len(shard.IterCounts), len(c.IterCounts))
+		}
+		for j, count := range shard.IterCounts {
+			if count.Name != c.IterCounts[j].Name {
+				return c, fmt.Errorf("shard %v subset %v is %q, expected %q", i, j, count.Name, c.IterCounts[j].Name)
+			}
+			c.IterCounts[j].Count = append(c.IterCounts[j].Count, count.Count...)
+			c.IterCounts[j].Covered = append(c.IterCounts[j].Covered, count.Covered...)
+		}
+	}
+	c.Probs = GetProbs(c.Overlaps, c.IterCounts)
+	GetDistanceProbs(c.Distances)
+	GetPairProbs(c.Pairs)
+	return
+}
+
+// Merge the shard files named in args, and write the result like FullCompare
+func FullMerge(w io.Writer, f Flags, paths []string) error {
+	var shards []Comparison
+	for _, path := range paths {
+		shard, err := ReadComparisonJSONPath(path)
+		if err != nil {
+			return fmt.Errorf("%v: %w", path, err)
+		}
+		shards = append(shards, shard)
+	}
+	c, err := MergeComparisons(shards...)
+	if err != nil {
+		return err
+	}
+	Log().Infof("merged %v shards, %v iterations in all", len(shards), c.Meta.Iterations)
+	return WriteOutputs(w, f, c)
+}
@@ -0,0 +1,67 @@
+package permuvals
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func shardComparison(beds Beds, genome Bed, seed, iterations int) Comparison {
+	c := Comparison{Overlaps: GetOverlaps(beds, -1)}
+	c.Meta = RunMeta{Iterations: iterations, Rseed: seed, OverlapOpts: OverlapOpts{MaxComps: -1}}
+	c.Meta.InputHash = InputHash(beds, genome, c.Meta)
+	randgen := rand.New(rand.NewSource(int64(seed)))
+	c.IterCounts = CountPermutations(Permutations(beds, genome, iterations, randgen, -1, nil))
+	c.Probs = GetProbs(c.Overlaps, c.IterCounts)
+	return c
+}
+
+func TestMergeComparisons(t *testing.T) {
+	beds, genome := checkpointInputs()
+	a := shardComparison(beds, genome, 1, 5)
+	b := shardComparison(beds, genome, 2, 7)
+
+	// round trip one shard through its serialized form
+	var buf bytes.Buffer
+	if err := FprintComparisonJSON(&buf, b); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ReadComparisonJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := MergeComparisons(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Meta.Iterations != 12 || !sameInts(merged.Meta.MergedSeeds, []int{1, 2}) || merged.Meta.InputHash != a.Meta.InputHash {
+		t.Errorf("merged meta %v", merged.Meta)
+	}
+	// the probabilities of the shards' null distributions put end to end
+	var concatenated OverlapCounts
+	for i, count := range a.IterCounts {
+		count.Count = append(append([]int{}, count.Count...), b.IterCounts[i].Count...)
+		count.Covered = append(append([]int{}, count.Covered...), b.IterCounts[i].Covered...)
+		concatenated = append(concatenated, count)
+	}
+	for i, count := range merged.IterCounts {
+		if !sameInts(count.Count, concatenated[i].Count) || !sameInts(count.Covered, concatenated[i].Covered) {
+			t.Errorf("merged counts %v, expected %v", count, concatenated[i])
+		}
+	}
+	probs := GetProbs(a.Overlaps, concatenated)
+	for i, p := range merged.Probs {
+		if p != probs[i] {
+			t.Errorf("merged prob %v, expected %v", p, probs[i])
+		}
+	}
+
+	if _, err := MergeComparisons(a, a); err == nil {
+		t.Errorf("merging shards with the same seed should fail")
+	}
+	other := shardComparison(Beds{beds[0], toBed("second", in1Bspans())}, genome, 3, 5)
+	if _, err := MergeComparisons(a, other); err == nil {
+		t.Errorf("merging shards with different inputs should fail")
+	}
+}
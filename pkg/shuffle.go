@@ -0,0 +1,79 @@
+package permuvals
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Make replicates permuted copies of beds with PermuteBeds. Drawing from
+// randgen in the same order as Permutations, replicate i with a given seed
+// holds the same beds that permutation i of a test with that seed compares.
+func Shuffle(beds Beds, genome Bed, replicates int, randgen *rand.Rand, toPermute []int) (reps []Beds) {
+	for i := 0; i < replicates; i++ {
+		reps = append(reps, PermuteBeds(beds, genome, randgen, toPermute))
+	}
+	return
+}
+
+// Write every replicate to one stream, as a bed with the columns chrom,
+// start, end, bed label and replicate number
+func FprintShuffled(w io.Writer, reps []Beds) {
+	for i, beds := range reps {
+		for _, b := range beds {
+			for _, span := range AllBedSpans(b) {
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", span.Chrom, span.Min, span.Max, b.Name, i)
+			}
+		}
+	}
+}
+
+// A bed label made safe to use in a file name
+func fileLabel(label string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(label)
+}
+
+// Write each replicate of each bed to its own file, named
+// prefix + label + "_" + replicate + ".bed". If prefix names a directory
+// (ends in a slash), it is created.
+func WriteShuffledFiles(prefix string, reps []Beds) error {
+	if err := os.MkdirAll(filepath.Dir(prefix + "x"), 0755); err != nil {
+		return err
+	}
+	for i, beds := range reps {
+		for _, b := range beds {
+			path := fmt.Sprintf("%v%v_%v.bed", prefix, fileLabel(b.Name), i)
+			err := writeFile(path, func(w io.Writer) error {
+				WriteBeds(w, b)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Shuffle the beds named in the flags with the seed, genome and beds to
+// permute of a test, and write the replicates to the files starting with
+// f.OutPrefix, or to w
+func FullShuffle(w io.Writer, f Flags, replicates int) (err error) {
+	genome, err := GetFlagsGenome(f)
+	if err != nil { return }
+	_, beds, toPermute, err := GetFlagsBeds(f)
+	if err != nil { return }
+	if err = CheckFits(beds, genome, toPermute); err != nil { return }
+	randgen := rand.New(rand.NewSource(int64(f.Rseed)))
+	reps := Shuffle(beds, genome, replicates, randgen, toPermute)
+	if f.OutPrefix != "" {
+		return WriteShuffledFiles(f.OutPrefix, reps)
+	}
+	bw := bufio.NewWriter(w)
+	FprintShuffled(bw, reps)
+	return bw.Flush()
+}
@@ -0,0 +1,80 @@
+package permuvals
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShuffleMatchesPermutations(t *testing.T) {
+	beds, genome := checkpointInputs()
+	reps := Shuffle(beds, genome, 10, rand.New(rand.NewSource(3)), []int{1})
+	perms := Permutations(beds, genome, 10, rand.New(rand.NewSource(3)), -1, []int{1})
+	if len(reps) != len(perms) {
+		t.Fatalf("%v replicates, expected %v", len(reps), len(perms))
+	}
+	for i, rep := range reps {
+		var actual, expected strings.Builder
+		FprintOvlsBed(&actual, GetOverlaps(rep, -1))
+		FprintOvlsBed(&expected, perms[i])
+		if actual.String() != expected.String() {
+			t.Errorf("replicate %v does not match permutation %v:\n%v\nexpected:\n%v", i, i, actual.String(), expected.String())
+		}
+
+		var first strings.Builder
+		FprintBeds(&first, rep[0])
+		var orig strings.Builder
+		FprintBeds(&orig, beds[0])
+		if first.String() != orig.String() {
+			t.Errorf("replicate %v changed a bed that is not permuted", i)
+		}
+		for _, span := range AllBedSpans(rep[1]) {
+			inside := false
+			for _, chrom := range AllBedSpans(genome) {
+				if chrom.Chrom == span.Chrom && chrom.Min <= span.Min && span.Max <= chrom.Max {
+					inside = true
+				}
+			}
+			if !inside {
+				t.Errorf("replicate %v span %v is outside the genome", i, span)
+			}
+		}
+	}
+}
+
+func TestMainShuffle(t *testing.T) {
+	a, b, genome := writeTestBeds(t)
+	status, stdout, stderr := runMain("shuffle", "-g", genome, "-n", "3", "-r", "5", "A=" + a, "B=" + b)
+	if status != 0 {
+		t.Fatalf("shuffle failed: %v", stderr)
+	}
+	reps := map[string]int{}
+	for _, line := range strings.Split(strings.TrimSuffix(stdout, "\n"), "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 5 {
+			t.Fatalf("line %q does not have 5 fields", line)
+		}
+		reps[fields[3] + " " + fields[4]]++
+	}
+	for _, key := range []string{"A 0", "A 1", "A 2", "B 0", "B 1", "B 2"} {
+		if reps[key] < 1 {
+			t.Errorf("no spans for %v in %v", key, reps)
+		}
+	}
+
+	prefix := filepath.Join(t.TempDir(), "out") + "/"
+	if status, _, stderr := runMain("shuffle", "-g", genome, "-n", "2", "-o", prefix, "A=" + a); status != 0 {
+		t.Fatalf("shuffle -o failed: %v", stderr)
+	}
+	for _, name := range []string{"A_0.bed", "A_1.bed"} {
+		if _, err := os.Stat(prefix + name); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if status, _, _ := runMain("shuffle", a); status != 2 {
+		t.Errorf("shuffle without a genome: status %v, expected 2", status)
+	}
+}
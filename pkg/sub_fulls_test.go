@@ -1,6 +1,7 @@
 package permuvals
 
 import (
+	"math/rand"
 	"testing"
 	"github.com/jgbaldwinbrown/go-intervals/intervalset"
 )
@@ -34,3 +35,79 @@ func TestSubFull(t *testing.T) {
 		}
 	}
 }
+
+func TestSubFullMin(t *testing.T) {
+	in1b := MakeBed("first")
+	in1b.AddBspans(MakeBspan("one", 0, 10), MakeBspan("one", 20, 40), MakeBspan("one", 50, 150), MakeBspan("two", 0, 10))
+	in2b := MakeBed("second")
+	in2b.AddBspans(MakeBspan("one", 8, 25), MakeBspan("one", 30, 32), MakeBspan("one", 140, 200))
+
+	cases := []struct {
+		min MinOverlap
+		expected []Bspan
+	} {
+		{MinOverlap{}, []Bspan{MakeBspan("two", 0, 10)}},
+		// [20,40) is overlapped by 5 and 2 bp, by different spans of second,
+		// and each span is checked on its own
+		{MinOverlap{Bp: 5}, []Bspan{MakeBspan("one", 0, 10), MakeBspan("two", 0, 10)}},
+		{MinOverlap{Bp: 7}, []Bspan{MakeBspan("one", 0, 10), MakeBspan("one", 20, 40), MakeBspan("two", 0, 10)}},
+		{MinOverlap{Bp: 8}, []Bspan{MakeBspan("one", 0, 10), MakeBspan("one", 20, 40), MakeBspan("two", 0, 10)}},
+		{MinOverlap{FracA: 0.2}, []Bspan{MakeBspan("one", 50, 150), MakeBspan("two", 0, 10)}},
+		{MinOverlap{Bp: 3, FracA: 0.1}, []Bspan{MakeBspan("one", 0, 10), MakeBspan("two", 0, 10)}},
+		// [8,25) is only 2 / 17 covered by [0,10); [20,40) covers all of
+		// [30,32), though not of [8,25)
+		{MinOverlap{FracB: 0.15}, []Bspan{MakeBspan("one", 0, 10), MakeBspan("two", 0, 10)}},
+		{MinOverlap{FracB: 0.5}, []Bspan{MakeBspan("one", 0, 10), MakeBspan("one", 50, 150), MakeBspan("two", 0, 10)}},
+	}
+	for _, c := range cases {
+		out := in1b.SubtractFullsBedMin(in2b, c.min)
+		subspans := AllBedSpans(out)
+		if len(subspans) != len(c.expected) {
+			t.Errorf("%+v: actual and expected do not match. Actual: %v. Expected: %v.", c.min, subspans, c.expected)
+			continue
+		}
+		for i, b := range subspans {
+			if b != c.expected[i] {
+				t.Errorf("%+v: actual and expected do not match. Actual: %v. Expected: %v.", c.min, subspans, c.expected)
+				break
+			}
+		}
+	}
+}
+
+// Compare with checking every pair of spans
+func TestSubFullRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randBed := func(name string, n int) Bed {
+		b := MakeBed(name)
+		for i := 0; i < n; i++ {
+			start := r.Intn(10000)
+			b.AddBspans(MakeBspan("one", start, start + 1 + r.Intn(100)))
+		}
+		return b
+	}
+	a, b := randBed("a", 300), randBed("b", 100)
+	out := a.SubtractFullsBed(b)
+
+	var expected []Bspan
+	for _, span := range AllBedSpans(a) {
+		overlaps := false
+		for _, bad := range AllBedSpans(b) {
+			if span.Min < bad.Max && bad.Min < span.Max {
+				overlaps = true
+			}
+		}
+		if !overlaps {
+			expected = append(expected, span)
+		}
+	}
+	subspans := AllBedSpans(out)
+	if len(subspans) != len(expected) {
+		t.Fatalf("actual and expected do not match. Actual: %v. Expected: %v.", subspans, expected)
+	}
+	for i := range subspans {
+		if subspans[i] != expected[i] {
+			t.Fatalf("actual and expected do not match. Actual: %v. Expected: %v.", subspans, expected)
+		}
+	}
+}
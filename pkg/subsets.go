@@ -0,0 +1,125 @@
+package permuvals
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parse one subset of bed labels, separated by colons as in the names of
+// overlaps (or by tabs)
+func ParseSubset(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '\t' })
+}
+
+// Parse a comma-separated list of subsets, e.g. "CTCF:enh,CTCF:TSS"
+func ParseSubsetList(s string) (subsets [][]string) {
+	for _, field := range strings.Split(s, ",") {
+		if subset := ParseSubset(field); len(subset) > 0 {
+			subsets = append(subsets, subset)
+		}
+	}
+	return
+}
+
+// Read one subset per line; blank lines and lines starting with # are skipped
+func ReadSubsets(r io.Reader) (subsets [][]string, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		subsets = append(subsets, ParseSubset(line))
+	}
+	err = s.Err()
+	return
+}
+
+// Read the subsets in the file at path
+func GetSubsets(path string) (subsets [][]string, err error) {
+	r, err := OpenPath(path)
+	if err != nil { return }
+	defer r.Close()
+	return ReadSubsets(r)
+}
+
+// The subsets pairing reference, as A, with each other bed, in bed order
+func ReferenceSubsets(beds Beds, reference string) (subsets [][]string) {
+	for _, b := range beds {
+		if b.Name != reference {
+			subsets = append(subsets, []string{reference, b.Name})
+		}
+	}
+	return
+}
+
+// Check that every label in subsets names one of beds, once per subset.
+// Labels are kept in the order given, since the first bed of a subset is A for
+// MinOverlap. Repeated subsets are dropped.
+func ResolveSubsets(beds Beds, subsets [][]string) (out [][]string, err error) {
+	labels := make(map[string]struct{}, len(beds))
+	for _, b := range beds {
+		labels[b.Name] = struct{}{}
+	}
+	seen := map[string]struct{}{}
+	for _, subset := range subsets {
+		if len(subset) < 1 {
+			return nil, fmt.Errorf("empty subset")
+		}
+		name := strings.Join(subset, ":")
+		in := make(map[string]struct{}, len(subset))
+		for _, label := range subset {
+			if _, ok := labels[label]; !ok {
+				return nil, fmt.Errorf("subset %v: no bed is labelled %q", name, label)
+			}
+			if _, ok := in[label]; ok {
+				return nil, fmt.Errorf("subset %v: %q is listed more than once", name, label)
+			}
+			in[label] = struct{}{}
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		out = append(out, subset)
+	}
+	return
+}
+
+// The overlaps of just the given subsets of beds, in that order. Each subset
+// must be resolved with ResolveSubsets. As in GetOverlap, single-bed subsets
+// are empty. Intersections are shared between subsets that start with the same
+// beds, as in SubsetOverlaps.
+func SelectedOverlaps(beds Beds, subsets [][]string) (overlaps Overlaps) {
+	byName := make(map[string]Bed, len(beds))
+	for _, b := range beds {
+		byName[b.Name] = b
+	}
+	// intersections of the leading beds of subsets, by name
+	inters := make(map[string]Bed)
+	var intersect func(components []string) Bed
+	intersect = func(components []string) Bed {
+		if len(components) == 1 {
+			return byName[components[0]]
+		}
+		name := strings.Join(components, ":")
+		if inter, ok := inters[name]; ok {
+			return inter
+		}
+		last := len(components) - 1
+		inter := intersectTwo(name, intersect(components[:last]), byName[components[last]])
+		inters[name] = inter
+		return inter
+	}
+	for _, subset := range subsets {
+		name := strings.Join(subset, ":")
+		if len(subset) == 1 {
+			overlaps = append(overlaps, Overlap{MakeBed(name), subset})
+			continue
+		}
+		overlaps = append(overlaps, Overlap{intersect(subset), subset})
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package permuvals
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestParseSubsets(t *testing.T) {
+	subsets := ParseSubsetList("a:b,,c")
+	if len(subsets) != 2 || !sameStrings(subsets[0], []string{"a", "b"}) || !sameStrings(subsets[1], []string{"c"}) {
+		t.Errorf("unexpected subsets %v", subsets)
+	}
+	subsets, err := ReadSubsets(strings.NewReader("# comment\na\tb\n\nb:c:a\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subsets) != 2 || !sameStrings(subsets[0], []string{"a", "b"}) || !sameStrings(subsets[1], []string{"b", "c", "a"}) {
+		t.Errorf("unexpected subsets %v", subsets)
+	}
+}
+
+func TestSelectedOverlaps(t *testing.T) {
+	beds := Beds{toBed("a", in1Bspans()), toBed("b", in2Bspans()), toBed("c", []Bspan{MakeBspan("one", 0, 100)})}
+	subsets, err := ResolveSubsets(beds, [][]string{{"c", "a"}, {"b", "c", "a"}, {"c", "a"}, {"b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subsets) != 3 || !sameStrings(subsets[0], []string{"c", "a"}) || !sameStrings(subsets[1], []string{"b", "c", "a"}) {
+		t.Errorf("unexpected resolved subsets %v", subsets)
+	}
+	if _, err := ResolveSubsets(beds, [][]string{{"a", "d"}}); err == nil {
+		t.Errorf("unknown label should be an error")
+	}
+	if _, err := ResolveSubsets(beds, [][]string{{"a", "b", "a"}}); err == nil {
+		t.Errorf("repeated label should be an error")
+	}
+
+	// the same intersections as the subsets of GetOverlaps, which are in
+	// mask order, named in the order given
+	all := GetOverlaps(beds, -1)
+	ovls := SelectedOverlaps(beds, subsets)
+	for i, mask := range []int{5, 7, 2} {
+		if name := strings.Join(subsets[i], ":"); ovls[i].Name != name {
+			t.Fatalf("subset %v is %v, expected %v", i, ovls[i].Name, name)
+		}
+		checkBspans(t, AllBedSpans(ovls[i].Bed), AllBedSpans(all[mask].Bed))
+	}
+
+	ref := ReferenceSubsets(beds, "c")
+	if len(ref) != 2 || !sameStrings(ref[0], []string{"c", "a"}) || !sameStrings(ref[1], []string{"c", "b"}) {
+		t.Errorf("unexpected reference subsets %v", ref)
+	}
+
+	opts := OverlapOpts{Subsets: subsets}
+	counts := CountPermutations(PermutationsOpts(beds, toBed("genome", genomeBspans()), 3, rand.New(rand.NewSource(1)), nil, opts))
+	if len(counts) != 3 || counts[1].Name != "b:c:a" || len(counts[1].Count) != 3 {
+		t.Errorf("unexpected permutation counts %v", counts)
+	}
+}
+
+// The reference is A for every subset of ReferenceSubsets, wherever it is
+// among the beds
+func TestReferenceSubsetsMinOverlap(t *testing.T) {
+	var ctcf, enh, tss []Bspan
+	for i := 0; i < 5; i++ {
+		enh = append(enh, MakeBspan("one", i * 1000, i * 1000 + 100))
+		ctcf = append(ctcf, MakeBspan("one", i * 1000 + 10, i * 1000 + 20))
+		tss = append(tss, MakeBspan("one", i * 1000 + 50, i * 1000 + 60))
+	}
+	beds := Beds{toBed("CTCF", ctcf), toBed("enh", enh), toBed("TSS", tss)}
+	subsets, err := ResolveSubsets(beds, ReferenceSubsets(beds, "enh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// each intersection is all of a 10 bp span of CTCF or TSS, but only a
+	// tenth of the span of enh
+	for _, c := range []struct {
+		min MinOverlap
+		count int
+	} {
+		{MinOverlap{FracA: 0.5}, 0},
+		{MinOverlap{FracB: 0.5}, 5},
+	} {
+		ovls := GetOverlapsOpts(beds, OverlapOpts{Subsets: subsets, Min: c.min})
+		if len(ovls) != 2 || ovls[0].Name != "enh:CTCF" || ovls[1].Name != "enh:TSS" {
+			t.Fatalf("unexpected subsets %v", ovls)
+		}
+		for _, ovl := range ovls {
+			if n := len(AllBedSpans(ovl.Bed)); n != c.count {
+				t.Errorf("%+v: %v has %v spans, expected %v", c.min, ovl.Name, n, c.count)
+			}
+		}
+	}
+}
@@ -1,45 +1,72 @@
 package permuvals
 
 import (
+	"sort"
+
 	"github.com/jgbaldwinbrown/go-intervals/intervalset"
-	"fmt"
 )
 
-// for each bspan, keep if it does not intersect src
-func SubtractFull(bspans []Bspan, src *intervalset.Set) []Bspan {
+// Whether span overlaps any one span of sorted by at least min, taking that
+// span as B as filterOverlap does. sorted must be sorted and not overlap
+// itself, as the spans of an intervalset.Set are.
+func overlapsBy(span Bspan, sorted []Bspan, min MinOverlap) bool {
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i].Max > span.Min })
+	for ; i < len(sorted) && sorted[i].Min < span.Max; i++ {
+		lo, hi := sorted[i].Min, sorted[i].Max
+		if span.Min > lo { lo = span.Min }
+		if span.Max < hi { hi = span.Max }
+		if min.Passes(hi - lo, span.Width(), sorted[i].Width()) {
+			return true
+		}
+	}
+	return false
+}
+
+// for each bspan, keep if it does not overlap any one span of src by at least
+// min. src must be sorted and not overlapping, e.g. from AllBspans
+func SubtractFullMin(bspans []Bspan, src []Bspan, min MinOverlap) []Bspan {
 	out := []Bspan{}
 	for _, bspan := range bspans {
-		fmt.Println("bspan:", bspan)
-		fmt.Println("src:", src)
-		intersected := src.Copy()
-		intersected.Intersect(intervalset.NewSet([]intervalset.Interval{&bspan.Span}))
-		intersections := intersected.AllIntervals()
-		if len(intersections) == 0 {
-			fmt.Println("no overlap")
+		if !overlapsBy(bspan, src, min) {
 			out = append(out, bspan)
 		}
 	}
 	return out
 }
 
+// for each bspan, keep if it does not intersect src
+func SubtractFull(bspans []Bspan, src *intervalset.Set) []Bspan {
+	return SubtractFullMin(bspans, AllBspans("", src), MinOverlap{})
+}
+
 // Run SubtractFull on each chromosome in b / src
 func (b *Bed) SubtractFullsBed(src Bed) Bed {
-	bspans := []Bspan{}
-	all_chroms := CombineChroms(*b, src)
-	for _, chrom := range all_chroms {
-		_, b_has_chrom := b.Intervals[chrom]
-		_, src_has_chrom := src.Intervals[chrom]
-		if !b_has_chrom {
-		} else if !src_has_chrom {
-			bspans = append(bspans, AllBspans(chrom, b.Intervals[chrom])...)
-		}else {
-			goods := AllBspans(chrom, b.Intervals[chrom])
-			bads := src.Intervals[chrom]
-			bspans = append(bspans, SubtractFull(goods, bads)...)
-			// b.Intervals[chrom].Sub(src.Intervals[chrom])
+	return b.SubtractFullsBedMin(src, MinOverlap{})
+}
+
+// Run SubtractFullMin on each chromosome in b / src. Each span of b takes
+// one binary search of src, so this scales to large beds.
+func (b *Bed) SubtractFullsBedMin(src Bed, min MinOverlap) Bed {
+	out := MakeBed(b.Name)
+	for _, chrom := range b.Chroms {
+		goods := AllBspans(chrom, b.Intervals[chrom])
+		if bads, ok := src.Intervals[chrom]; ok {
+			goods = SubtractFullMin(goods, AllBspans(chrom, bads), min)
 		}
+		// goods are a subset of a set's spans, so still sorted and apart
+		out.addSortedBspans(chrom, goods)
 	}
-	out := MakeBed(b.Name)
-	out.AddBspans(bspans...)
 	return out
 }
+
+// Add spans, which must be sorted and neither overlap nor adjoin each other,
+// as a new chromosome of b, without inserting them one at a time
+func (b *Bed) addSortedBspans(chrom string, spans []Bspan) {
+	ivals := make([]intervalset.Interval, 0, len(spans))
+	for i := range spans {
+		span := spans[i].Span
+		ivals = append(ivals, &span)
+	}
+	b.Intervals[chrom] = intervalset.NewSetV1(ivals, intervalset.MakeZeroSpan)
+	b.Chroms = append(b.Chroms, chrom)
+}
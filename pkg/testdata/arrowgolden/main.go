@@ -0,0 +1,76 @@
+// Writes the golden Arrow IPC stream used by TestArrowGolden with the Apache
+// Arrow Go implementation, and prints any Arrow stream so that the output of
+// WriteArrowStream can be checked against a real reader. This is its own
+// module so that permuvals does not depend on arrow:
+//
+//	go run . write > ../null.arrow
+//	go run . read < stream.arrow
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+)
+
+// Keep in step with goldenArrowColumns in arrow_test.go
+func write() error {
+	schema := arrow.NewSchema([]arrow.Field{
+		{Name: "iteration", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "name", Type: arrow.BinaryTypes.String},
+		{Name: "n_components", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "count", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "covered", Type: arrow.PrimitiveTypes.Int64},
+	}, nil)
+	b := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer b.Release()
+	b.Field(0).(*array.Int64Builder).AppendValues([]int64{0, 1, 0, 1, 2}, nil)
+	b.Field(1).(*array.StringBuilder).AppendValues([]string{"first", "first", "first:second", "first:second", ""}, nil)
+	b.Field(2).(*array.Int64Builder).AppendValues([]int64{1, 1, 2, 2, 0}, nil)
+	b.Field(3).(*array.Int64Builder).AppendValues([]int64{3, 0, 1, -1, 1 << 40}, nil)
+	b.Field(4).(*array.Int64Builder).AppendValues([]int64{30, 0, 8, 0, 7}, nil)
+	rec := b.NewRecord()
+	defer rec.Release()
+
+	w := ipc.NewWriter(os.Stdout, ipc.WithSchema(schema))
+	if err := w.Write(rec); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func read() error {
+	r, err := ipc.NewReader(os.Stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Release()
+	fmt.Println(r.Schema())
+	for r.Next() {
+		rec := r.Record()
+		for i, col := range rec.Columns() {
+			fmt.Printf("%v: %v\n", rec.ColumnName(i), col)
+		}
+	}
+	return r.Err()
+}
+
+func main() {
+	var err error
+	switch {
+	case len(os.Args) == 2 && os.Args[1] == "write":
+		err = write()
+	case len(os.Args) == 2 && os.Args[1] == "read":
+		err = read()
+	default:
+		err = fmt.Errorf("usage: %v write|read", os.Args[0])
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}